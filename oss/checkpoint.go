@@ -0,0 +1,102 @@
+package oss
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Checkpoint 可续传的分片上传进度
+type Checkpoint struct {
+	Key      string `json:"key"`
+	UploadId string `json:"uploadId"`
+
+	// Size、PartSize 用于判断续传的是否为同一个文件及切分方式，不一致时重新上传
+	Size     int64 `json:"size"`
+	PartSize int64 `json:"partSize"`
+
+	// Parts 已上传的分片，续传时以 ListParts 的结果为准
+	Parts []*CompletedPart `json:"parts"`
+}
+
+// CheckpointStore 保存上传进度，id 由调用方指定，同一时间只应有一个上传使用同一个 id
+type CheckpointStore interface {
+	// Load 读取进度，不存在时返回 nil, nil
+	Load(ctx context.Context, id string) (*Checkpoint, error)
+
+	Save(ctx context.Context, id string, checkpoint *Checkpoint) error
+
+	// Delete 删除进度，不存在时不返回错误
+	Delete(ctx context.Context, id string) error
+}
+
+type fileCheckpointStore struct {
+	dir string
+}
+
+// NewFileCheckpointStore 将进度保存为 dir 下的 json 文件，文件名为 id 的 sha256
+func NewFileCheckpointStore(dir string) (CheckpointStore, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &fileCheckpointStore{dir: dir}, nil
+}
+
+func (s *fileCheckpointStore) path(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (s *fileCheckpointStore) Load(ctx context.Context, id string) (*Checkpoint, error) {
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	checkpoint := &Checkpoint{}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// Save 先写入临时文件再重命名，进程中断时不会留下不完整的进度
+func (s *fileCheckpointStore) Save(ctx context.Context, id string, checkpoint *Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.dir, ".checkpoint-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(id))
+}
+
+func (s *fileCheckpointStore) Delete(ctx context.Context, id string) error {
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package oss
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultChunkSize           = 8 << 20
+	DefaultDownloadConcurrency = 4
+)
+
+// Downloader 按范围并发下载大文件，仅依赖 Oss 接口的 Stat 及 DownloadRange，适用于任意实现
+type Downloader struct {
+	store Oss
+
+	// ChunkSize 每次请求的范围大小
+	ChunkSize int64
+
+	// Concurrency 同时下载的范围数
+	Concurrency int
+
+	// MaxRetries 单个范围失败后的最多重试次数
+	MaxRetries int
+
+	// RetryDelay 首次重试前的等待时间，之后每次翻倍
+	RetryDelay time.Duration
+}
+
+// NewDownloader 创建 Downloader，opts 用于修改默认参数
+func NewDownloader(store Oss, opts ...func(*Downloader)) *Downloader {
+	d := &Downloader{
+		store:       store,
+		ChunkSize:   DefaultChunkSize,
+		Concurrency: DefaultDownloadConcurrency,
+		MaxRetries:  DefaultMaxRetries,
+		RetryDelay:  DefaultRetryDelay,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Download 下载文件写入 w 的对应位置，返回文件大小
+// 每个范围读取的长度须与 Stat 获取的大小一致；下载期间文件被修改时返回 ErrPreconditionFailed
+// 失败时 w 中可能已写入部分数据
+func (d *Downloader) Download(ctx context.Context, key string, w io.WriterAt) (int64, error) {
+	if d.ChunkSize <= 0 {
+		return 0, fmt.Errorf("oss: invalid chunk size %d", d.ChunkSize)
+	}
+	info, err := d.store.Stat(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if err := d.downloadChunks(ctx, key, info.Size, w); err != nil {
+		return 0, err
+	}
+
+	// 各范围可能来自不同版本的文件
+	after, err := d.store.Stat(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if after.Size != info.Size || !etagEqual(after.ETag, info.ETag) {
+		return 0, fmt.Errorf("%w: %q was modified during download", ErrPreconditionFailed, key)
+	}
+	return info.Size, nil
+}
+
+func (d *Downloader) downloadChunks(ctx context.Context, key string, size int64, w io.WriterAt) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+		mu.Unlock()
+	}
+
+	concurrency := d.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	for offset := int64(0); offset < size; offset += d.ChunkSize {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			fail(err)
+			break
+		}
+
+		length := d.ChunkSize
+		if offset+length > size {
+			length = size - offset
+		}
+		wg.Add(1)
+		go func(offset, length int64) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			err := retry(ctx, d.MaxRetries, d.RetryDelay, func() error {
+				return d.downloadChunk(ctx, key, offset, length, w)
+			})
+			if err != nil {
+				fail(fmt.Errorf("download range %d-%d: %w", offset, offset+length-1, err))
+			}
+		}(offset, length)
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+func (d *Downloader) downloadChunk(ctx context.Context, key string, offset, length int64, w io.WriterAt) error {
+	reader, err := d.store.DownloadRange(ctx, key, offset, length)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	n, err := io.Copy(&offsetWriter{w: w, offset: offset}, io.LimitReader(reader, length))
+	if err != nil {
+		return err
+	}
+	if n != length {
+		return fmt.Errorf("got %d bytes, expected %d: %w", n, length, io.ErrUnexpectedEOF)
+	}
+	return nil
+}
+
+// offsetWriter 从 offset 开始顺序写入 w
+type offsetWriter struct {
+	w      io.WriterAt
+	offset int64
+}
+
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	n, err := o.w.WriteAt(p, o.offset)
+	o.offset += int64(n)
+	return n, err
+}
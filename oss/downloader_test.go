@@ -0,0 +1,149 @@
+package oss_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/blues120/ias-kit/oss"
+	"github.com/blues120/ias-kit/oss/local"
+	"github.com/blues120/ias-kit/oss/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDownloader(store oss.Oss) *oss.Downloader {
+	return oss.NewDownloader(store, func(d *oss.Downloader) {
+		d.ChunkSize = 1000
+		d.RetryDelay = 0
+	})
+}
+
+// countCalls 统计 op 调用次数的 Hook
+func countCalls(calls *int64, op string) memory.Hook {
+	return func(ctx context.Context, o, key string) error {
+		if o == op {
+			atomic.AddInt64(calls, 1)
+		}
+		return nil
+	}
+}
+
+// shortReadStore 前 shortReads 次 DownloadRange 只返回一半的数据，模拟连接提前关闭
+type shortReadStore struct {
+	oss.Oss
+	shortReads int64
+}
+
+func (s *shortReadStore) DownloadRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	reader, err := s.Oss.DownloadRange(ctx, key, offset, length)
+	if err != nil || atomic.AddInt64(&s.shortReads, -1) < 0 {
+		return reader, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(reader, length/2), reader}, nil
+}
+
+func downloadFile(t *testing.T, downloader *oss.Downloader, key string) ([]byte, error) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "download"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	n, err := downloader.Download(context.Background(), key, f)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(f.Name())
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), n)
+	return data, nil
+}
+
+func TestDownloader_Download(t *testing.T) {
+	ctx := context.Background()
+	store, err := local.NewLocal(t.TempDir(), "")
+	require.NoError(t, err)
+
+	for _, size := range []int{0, 10, 1000, 10*1000 + 7} {
+		data := make([]byte, size)
+		rand.Read(data)
+		require.NoError(t, store.Upload(ctx, "key", bytes.NewReader(data)))
+
+		actual, err := downloadFile(t, newDownloader(store), "key")
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(data, actual))
+	}
+
+	_, err = downloadFile(t, newDownloader(store), "not-exists")
+	require.ErrorIs(t, err, oss.ErrNotFound)
+}
+
+func TestDownloader_Retry(t *testing.T) {
+	ctx := context.Background()
+	data := make([]byte, 5000)
+	rand.Read(data)
+
+	// 前两次下载分片时连接中断
+	var calls int64
+	store := memory.NewMemory(
+		memory.WithHook(countCalls(&calls, "DownloadRange")),
+		memory.WithHook(memory.FailTimes(2, io.ErrUnexpectedEOF, "DownloadRange")),
+	)
+	require.NoError(t, store.Upload(ctx, "key", bytes.NewReader(data)))
+	actual, err := downloadFile(t, newDownloader(store), "key")
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(data, actual))
+	require.Equal(t, int64(7), atomic.LoadInt64(&calls))
+
+	// 重试次数用尽
+	store = memory.NewMemory(memory.WithHook(memory.Fail(io.ErrUnexpectedEOF, "DownloadRange")))
+	require.NoError(t, store.Upload(ctx, "key", bytes.NewReader(data)))
+	_, err = downloadFile(t, newDownloader(store), "key")
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// 前两次下载分片时只返回部分数据
+	calls = 0
+	store = memory.NewMemory(memory.WithHook(countCalls(&calls, "DownloadRange")))
+	require.NoError(t, store.Upload(ctx, "key", bytes.NewReader(data)))
+	actual, err = downloadFile(t, newDownloader(&shortReadStore{Oss: store, shortReads: 2}), "key")
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(data, actual))
+	require.Equal(t, int64(7), atomic.LoadInt64(&calls))
+
+	// 每次都只返回部分数据，重试次数用尽
+	_, err = downloadFile(t, newDownloader(&shortReadStore{Oss: store, shortReads: math.MaxInt64}), "key")
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestDownloader_Modified(t *testing.T) {
+	ctx := context.Background()
+	data := make([]byte, 5000)
+	rand.Read(data)
+
+	// 首次下载分片时修改文件
+	var (
+		store oss.Oss
+		once  sync.Once
+	)
+	store = memory.NewMemory(memory.WithHook(func(ctx context.Context, op, key string) error {
+		if op == "DownloadRange" {
+			once.Do(func() {
+				changed := bytes.Repeat([]byte{'x'}, len(data))
+				assert.NoError(t, store.Upload(ctx, "key", bytes.NewReader(changed)))
+			})
+		}
+		return nil
+	}))
+	require.NoError(t, store.Upload(ctx, "key", bytes.NewReader(data)))
+	_, err := downloadFile(t, newDownloader(store), "key")
+	require.ErrorIs(t, err, oss.ErrPreconditionFailed)
+}
@@ -0,0 +1,71 @@
+package oss
+
+import "errors"
+
+var (
+	// ErrNotFound 文件不存在
+	ErrNotFound = errors.New("oss: object not found")
+
+	// ErrAlreadyExists 文件已存在
+	ErrAlreadyExists = errors.New("oss: object already exists")
+
+	// ErrPermission 没有访问权限
+	ErrPermission = errors.New("oss: permission denied")
+
+	// ErrPreconditionFailed 条件请求的条件不满足
+	ErrPreconditionFailed = errors.New("oss: precondition failed")
+
+	// ErrInvalidKey key 不合法，见 NormalizeKey 及 ValidateKey
+	ErrInvalidKey = errors.New("oss: invalid key")
+
+	// ErrInvalidRange 请求的范围超出文件大小
+	ErrInvalidRange = errors.New("oss: requested range not satisfiable")
+
+	// ErrExpireTooLong 临时链接过期时间超过 MaxUrlExpire
+	ErrExpireTooLong = errors.New("oss: the expiration time is up to 7 days")
+
+	// ErrNotSupported 当前实现不支持该操作
+	ErrNotSupported = errors.New("oss: operation not supported")
+
+	// ErrUploadNotFound 分片上传不存在，或已完成、已终止
+	ErrUploadNotFound = errors.New("oss: multipart upload not found")
+
+	// ErrInvalidTags 标签数量或长度超出限制，见 ValidateTags
+	ErrInvalidTags = errors.New("oss: invalid tags")
+
+	// ErrEmptyPrefix DeletePrefix 的 prefix 为空，为避免误删整个存储而拒绝执行
+	ErrEmptyPrefix = errors.New("oss: the prefix must not be empty")
+)
+
+// DeleteError 批量删除时单个文件的错误
+type DeleteError struct {
+	Key string
+	Err error
+}
+
+func (e *DeleteError) Error() string {
+	return e.Key + ": " + e.Err.Error()
+}
+
+func (e *DeleteError) Unwrap() error {
+	return e.Err
+}
+
+// Error 存储实现返回的原始错误及其对应的类型
+// errors.Is(err, Kind) 成立，同时可以通过 errors.As 获取原始错误，如 awserr.Error、*fs.PathError
+type Error struct {
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
@@ -0,0 +1,24 @@
+package oss
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestError(t *testing.T) {
+	native := &fs.PathError{Op: "open", Path: "a", Err: fs.ErrNotExist}
+	err := fmt.Errorf("download: %w", &Error{Kind: ErrNotFound, Err: native})
+
+	require.ErrorIs(t, err, ErrNotFound)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.False(t, errors.Is(err, ErrPermission))
+
+	var pathErr *fs.PathError
+	require.ErrorAs(t, err, &pathErr)
+	require.Equal(t, "a", pathErr.Path)
+	require.Equal(t, "download: oss: object not found: open a: file does not exist", err.Error())
+}
@@ -0,0 +1,32 @@
+package oss
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// 与 s3 相同，ETag 带双引号
+//   - 普通上传为文件内容的 md5
+//   - 分片上传为各分片 md5 拼接后的 md5，再加上 "-分片数"
+//
+// s3 的 ETag 由服务端生成，以下函数供其他实现生成相同格式的 ETag
+
+// MD5ETag 根据 md5 生成 ETag
+func MD5ETag(sum []byte) string {
+	return `"` + hex.EncodeToString(sum) + `"`
+}
+
+// MultipartETag 根据各分片的 ETag 生成分片上传文件的 ETag
+func MultipartETag(partETags []string) (string, error) {
+	h := md5.New()
+	for _, etag := range partETags {
+		sum, err := hex.DecodeString(strings.Trim(etag, `"`))
+		if err != nil {
+			return "", fmt.Errorf("invalid part etag %s: %w", etag, err)
+		}
+		h.Write(sum)
+	}
+	return fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(h.Sum(nil)), len(partETags)), nil
+}
@@ -0,0 +1,20 @@
+package oss
+
+import (
+	"crypto/md5"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultipartETag(t *testing.T) {
+	sum := md5.Sum([]byte("1"))
+	require.Equal(t, `"c4ca4238a0b923820dcc509a6f75849b"`, MD5ETag(sum[:]))
+
+	etag, err := MultipartETag([]string{MD5ETag(sum[:]), MD5ETag(sum[:])})
+	require.NoError(t, err)
+	require.Regexp(t, `^"[0-9a-f]{32}-2"$`, etag)
+
+	_, err = MultipartETag([]string{`"not-hex"`})
+	require.Error(t, err)
+}
@@ -0,0 +1,90 @@
+package oss
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxKeyLength 与 s3 相同，key 最长 1024 字节
+const MaxKeyLength = 1024
+
+// NormalizeKey 校验并规范化 key，各 Oss 实现使用同一规则，保证 key 在不同存储中表现一致
+//   - 不能为空、不能超过 MaxKeyLength、须为合法的 utf-8
+//   - 不能以 "/" 开头或结尾，不能包含 ".." 段、NUL 字符及 "\"
+//   - 连续的 "/" 合并为一个，"." 段被去掉，如 "a//./b" 规范化为 "a/b"
+//
+// 校验失败时返回的错误满足 errors.Is(err, ErrInvalidKey)
+func NormalizeKey(key string) (string, error) {
+	if err := checkPath(key); err != nil {
+		return "", err
+	}
+	if strings.HasSuffix(key, "/") {
+		return "", invalidKey(key, "ends with a slash")
+	}
+
+	segments := strings.Split(key, "/")
+	ret := segments[:0]
+	for _, segment := range segments {
+		if segment == "" || segment == "." {
+			continue
+		}
+		ret = append(ret, segment)
+	}
+	if len(ret) == 0 {
+		return "", invalidKey(key, "is empty")
+	}
+	return strings.Join(ret, "/"), nil
+}
+
+// ValidateKey 校验 key 但不做规范化，用于 key 与路径无关的存储，如 s3
+// s3 的 key 是不透明的，"a//b"、"dir/"、"/a" 等都是不同的合法 key，规范化后会访问到其他文件
+//   - 不能为空、不能超过 MaxKeyLength、须为合法的 utf-8
+//   - 不能包含 ".." 段及 NUL 字符
+//
+// 校验失败时返回的错误满足 errors.Is(err, ErrInvalidKey)
+func ValidateKey(key string) error {
+	switch {
+	case key == "":
+		return invalidKey(key, "is empty")
+	case len(key) > MaxKeyLength:
+		return invalidKey(key, fmt.Sprintf("is longer than %d bytes", MaxKeyLength))
+	case !utf8.ValidString(key):
+		return invalidKey(key, "is not valid utf-8")
+	case strings.ContainsRune(key, 0):
+		return invalidKey(key, "contains a NUL byte")
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." {
+			return invalidKey(key, `contains a ".." segment`)
+		}
+	}
+	return nil
+}
+
+// ValidatePrefix 校验列举、删除时使用的前缀
+// 前缀可以为空或以 "/" 结尾，其余规则与 NormalizeKey 相同，但不做规范化
+func ValidatePrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	return checkPath(prefix)
+}
+
+// checkPath 在 ValidateKey 的基础上，拒绝映射为文件路径时有歧义的 key
+func checkPath(key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	switch {
+	case strings.ContainsRune(key, '\\'):
+		return invalidKey(key, "contains a backslash")
+	case strings.HasPrefix(key, "/"):
+		return invalidKey(key, "is an absolute path")
+	}
+	return nil
+}
+
+func invalidKey(key, reason string) error {
+	return fmt.Errorf("%w: %q %s", ErrInvalidKey, key, reason)
+}
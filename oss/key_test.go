@@ -0,0 +1,61 @@
+package oss
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeKey(t *testing.T) {
+	valid := map[string]string{
+		"a":             "a",
+		"a/b/c.txt":     "a/b/c.txt",
+		"a//b":          "a/b",
+		"./a/./b":       "a/b",
+		"a/..b/c..":     "a/..b/c..",
+		"中文/文件.txt":     "中文/文件.txt",
+		"tenant/2024/x": "tenant/2024/x",
+	}
+	for key, expected := range valid {
+		actual, err := NormalizeKey(key)
+		require.NoError(t, err, key)
+		require.Equal(t, expected, actual)
+	}
+
+	invalid := []string{
+		"",
+		".",
+		"/etc/passwd",
+		"../../etc/passwd",
+		"a/../../b",
+		"a/..",
+		"a/",
+		"a\x00b",
+		`a\b`,
+		"\xff",
+		strings.Repeat("a", MaxKeyLength+1),
+	}
+	for _, key := range invalid {
+		_, err := NormalizeKey(key)
+		require.ErrorIs(t, err, ErrInvalidKey, key)
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	for _, key := range []string{"a", "a//b", "x/./y", "dir/", "/a", `a\b`, "a/..b"} {
+		require.NoError(t, ValidateKey(key), key)
+	}
+	for _, key := range []string{"", "..", "../a", "a/../b", "a/..", "a\x00b", "\xff", strings.Repeat("a", MaxKeyLength+1)} {
+		require.ErrorIs(t, ValidateKey(key), ErrInvalidKey, key)
+	}
+}
+
+func TestValidatePrefix(t *testing.T) {
+	for _, prefix := range []string{"", "a", "a/", "a/b"} {
+		require.NoError(t, ValidatePrefix(prefix), prefix)
+	}
+	for _, prefix := range []string{"/a", "../", "a/../", "a\x00"} {
+		require.ErrorIs(t, ValidatePrefix(prefix), ErrInvalidKey, prefix)
+	}
+}
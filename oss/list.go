@@ -0,0 +1,46 @@
+package oss
+
+import "strings"
+
+// PaginateObjects 对以 prefix 开头、按 key 排序的所有对象分页，供 s3 以外的实现使用
+// 设置分隔符时，将 prefix 之后包含分隔符的 key 归组为公共前缀
+// ContinuationToken 为上一页最后返回的 key 或公共前缀
+func PaginateObjects(objects []*ObjectInfo, prefix string, opts *ListOptions) *ListResult {
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+	maxKeys := opts.MaxKeys
+	if maxKeys == 0 {
+		maxKeys = 1000
+	}
+
+	ret := &ListResult{
+		Objects:        make([]*ObjectInfo, 0),
+		CommonPrefixes: make([]string, 0),
+	}
+	var last string
+	for _, obj := range objects {
+		entry, isPrefix := obj.Key, false
+		if opts.Delimiter != "" {
+			if i := strings.Index(obj.Key[len(prefix):], opts.Delimiter); i >= 0 {
+				entry, isPrefix = obj.Key[:len(prefix)+i+len(opts.Delimiter)], true
+			}
+		}
+		if entry <= opts.ContinuationToken || entry == last {
+			continue
+		}
+
+		if int64(len(ret.Objects)+len(ret.CommonPrefixes)) >= maxKeys {
+			ret.IsTruncated = true
+			ret.NextContinuationToken = last
+			break
+		}
+		if isPrefix {
+			ret.CommonPrefixes = append(ret.CommonPrefixes, entry)
+		} else {
+			ret.Objects = append(ret.Objects, obj)
+		}
+		last = entry
+	}
+	return ret
+}
@@ -0,0 +1,40 @@
+package oss
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaginateObjects(t *testing.T) {
+	var objects []*ObjectInfo
+	for _, key := range []string{"p/a", "p/b/1", "p/b/2", "p/c", "p/d/1"} {
+		objects = append(objects, &ObjectInfo{Key: key})
+	}
+	keys := func(ret *ListResult) []string {
+		var keys []string
+		for _, obj := range ret.Objects {
+			keys = append(keys, obj.Key)
+		}
+		return keys
+	}
+
+	ret := PaginateObjects(objects, "p/", nil)
+	require.Len(t, ret.Objects, 5)
+	require.Empty(t, ret.CommonPrefixes)
+	require.False(t, ret.IsTruncated)
+
+	// 公共前缀与对象一起计入 MaxKeys
+	opts := &ListOptions{Delimiter: "/", MaxKeys: 2}
+	ret = PaginateObjects(objects, "p/", opts)
+	require.Equal(t, []string{"p/a"}, keys(ret))
+	require.Equal(t, []string{"p/b/"}, ret.CommonPrefixes)
+	require.True(t, ret.IsTruncated)
+	require.Equal(t, "p/b/", ret.NextContinuationToken)
+
+	opts.ContinuationToken = ret.NextContinuationToken
+	ret = PaginateObjects(objects, "p/", opts)
+	require.Equal(t, []string{"p/c"}, keys(ret))
+	require.Equal(t, []string{"p/d/"}, ret.CommonPrefixes)
+	require.False(t, ret.IsTruncated)
+}
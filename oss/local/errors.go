@@ -0,0 +1,48 @@
+package local
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+
+	"github.com/blues120/ias-kit/oss"
+)
+
+// convertError 将文件系统的错误转为 oss 包定义的错误类型，原始错误可通过 errors.As 获取
+func convertError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var ossErr *oss.Error
+	if errors.As(err, &ossErr) {
+		return err
+	}
+
+	var kind error
+	switch {
+	// 读取时 key 的上级路径是文件而不是目录时返回 ENOTDIR，与 s3 一致视为不存在
+	// 写入时的 ENOTDIR 由 makeParentDir 转为 ErrAlreadyExists
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, syscall.ENOTDIR):
+		kind = oss.ErrNotFound
+	// 删除非空目录时的 ENOTEMPTY 同样满足 fs.ErrExist，但与文件已存在无关
+	case errors.Is(err, syscall.ENOTEMPTY):
+		return err
+	case errors.Is(err, fs.ErrExist):
+		kind = oss.ErrAlreadyExists
+	case errors.Is(err, fs.ErrPermission):
+		kind = oss.ErrPermission
+	default:
+		return err
+	}
+	return &oss.Error{Kind: kind, Err: err}
+}
+
+// isNotFound 文件不存在，或 key 的上级路径不是目录
+func isNotFound(err error) bool {
+	return errors.Is(convertError(err), oss.ErrNotFound)
+}
+
+// wrapError 供方法返回前转换错误，用法为 defer wrapError(&err)
+func wrapError(err *error) {
+	*err = convertError(*err)
+}
@@ -0,0 +1,24 @@
+package local
+
+import (
+	"crypto/md5"
+	"io"
+	"os"
+
+	"github.com/blues120/ias-kit/oss"
+)
+
+// fileETag 计算已有文件的 ETag，用于没有 sidecar 的文件
+func fileETag(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return oss.MD5ETag(h.Sum(nil)), nil
+}
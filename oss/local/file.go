@@ -0,0 +1,166 @@
+package local
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// tempPrefix 写入中的临时文件名前缀，列举时跳过，也不能作为 key 的一部分
+const tempPrefix = ".oss-tmp-"
+
+// writeFileAtomic 先写入同目录下的临时文件并 fsync，再重命名为 p
+// 读取方只会看到旧文件或完整的新文件，不会看到写了一半的文件；写入失败时原文件保持不变
+func writeFileAtomic(p string, write func(w io.Writer) error) error {
+	tmp, err := writeTempFile(filepath.Dir(p), write)
+	if err != nil {
+		return err
+	}
+	return commitFile(tmp, p)
+}
+
+// writeTempFile 在 dir 下写入临时文件并 fsync，返回临时文件路径，写入失败时删除临时文件
+// 须在之后调用 commitFile 或删除临时文件
+func writeTempFile(dir string, write func(w io.Writer) error) (_ string, err error) {
+	f, err := os.CreateTemp(dir, tempPrefix+"*")
+	if err != nil {
+		return "", err
+	}
+	tmp := f.Name()
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	if err = write(f); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+	// CreateTemp 创建的文件权限为 0600
+	if err = os.Chmod(tmp, 0644); err != nil {
+		return "", err
+	}
+	return tmp, nil
+}
+
+// commitFile 将临时文件重命名为 p，失败时删除临时文件
+func commitFile(tmp, p string) error {
+	if err := os.Rename(tmp, p); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(p))
+}
+
+// linkFileAtomic 通过硬链接将 src 原子地复制为 dst，文件系统不支持硬链接时返回错误
+func linkFileAtomic(src, dst string) error {
+	dir := filepath.Dir(dst)
+	tmp := filepath.Join(dir, tempPrefix+uuid.New().String())
+	if err := os.Link(src, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return syncDir(dir)
+}
+
+// linkOrCopyFile 优先使用硬链接复制文件，文件系统不支持时复制文件内容
+// 硬链接的两个文件共享数据，之后任意一方的写入都是原子替换，不会改写另一方
+func linkOrCopyFile(src, dst string) error {
+	if err := linkFileAtomic(src, dst); err != nil {
+		return copyFileAtomic(src, dst)
+	}
+	return nil
+}
+
+// backupFile 在 p 所在目录下创建 p 的临时硬链接，不支持硬链接时复制文件内容
+// 用于替换 p 失败时通过 restoreFile 恢复，p 不存在时返回空字符串
+func backupFile(p string) (string, error) {
+	if _, err := statObject(p); err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	tmp := filepath.Join(filepath.Dir(p), tempPrefix+uuid.New().String())
+	if err := os.Link(p, tmp); err == nil {
+		return tmp, nil
+	}
+
+	in, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	return writeTempFile(filepath.Dir(p), func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+}
+
+// restoreFile 将 p 恢复为 backupFile 创建的备份，backup 为空时说明原先不存在，删除 p
+func restoreFile(backup, p string) error {
+	if backup == "" {
+		return os.Remove(p)
+	}
+	return commitFile(backup, p)
+}
+
+// copyFileAtomic 复制文件内容
+func copyFileAtomic(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return writeFileAtomic(dst, func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+}
+
+// syncDir fsync 目录，保证重命名在崩溃后依然生效
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// 部分平台（如 windows）不支持对目录 fsync，忽略错误
+	_ = d.Sync()
+	return nil
+}
+
+// isTempFile 是否为写入中的临时文件
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, tempPrefix)
+}
+
+// statObject 获取 key 对应文件的信息，嵌套 key 的上级目录等非普通文件视为不存在
+// 读取对象时使用 local.statKey，其在此基础上隐藏已过期的文件
+func statObject(p string) (fs.FileInfo, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, &fs.PathError{Op: "stat", Path: p, Err: fs.ErrNotExist}
+	}
+	return info, nil
+}
@@ -0,0 +1,176 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/blues120/ias-kit/oss"
+)
+
+// NewHandler 返回处理本地存储链接的 http.Handler，需挂载在 NewLocal 的 path 前缀下
+//   - GET/HEAD 校验签名及过期时间后返回文件，支持 Range 及 ETag；ACL 为 public-read 的文件无需签名
+//   - PUT 校验签名后上传文件或分片，对应 GenerateUploadUrl 及 GenerateUploadPartUrl
+func NewHandler(store oss.Oss) (http.Handler, error) {
+	r, ok := store.(*local)
+	if !ok {
+		return nil, fmt.Errorf("the store is not a local store")
+	}
+	return r, nil
+}
+
+func (r *local) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	key, ok := r.getKeyFromUrl(req.URL)
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	key, err := r.checkKey(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		r.serveDownload(w, req, key)
+	case http.MethodPut:
+		r.serveUpload(w, req, key)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (r *local) serveDownload(w http.ResponseWriter, req *http.Request, key string) {
+	// 未签名的链接仅允许访问公开读的文件
+	if req.URL.Query().Has(querySignature) {
+		if err := r.verifyUrl(req, http.MethodGet, key); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	} else {
+		meta, err := r.readMeta(key)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if meta.ACL != oss.AclPublicRead && meta.ACL != oss.AclPublicReadWrite {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+	}
+
+	info, err := r.Stat(req.Context(), key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	f, err := r.openKey(key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer f.Close()
+
+	header := w.Header()
+	header.Set("ETag", info.ETag)
+	if info.ETag == "" {
+		// 没有 ETag 时使用文件大小及修改时间生成弱校验值
+		header.Set("ETag", fmt.Sprintf(`W/"%x-%x"`, info.Size, info.LastModified.UnixNano()))
+	}
+	header.Set("Content-Type", info.ContentType)
+	setHeader := func(name, value string) {
+		if value != "" {
+			header.Set(name, value)
+		}
+	}
+	setHeader("Content-Disposition", info.ContentDisposition)
+	setHeader("Cache-Control", info.CacheControl)
+	setHeader("Content-Encoding", info.ContentEncoding)
+	for k, v := range info.Metadata {
+		header.Set("X-Amz-Meta-"+k, v)
+	}
+
+	// ServeContent 处理 Range、If-Match、If-None-Match 等请求头
+	http.ServeContent(w, req, key, info.LastModified, f)
+}
+
+func (r *local) serveUpload(w http.ResponseWriter, req *http.Request, key string) {
+	if err := r.verifyUrl(req, http.MethodPut, key); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	defer req.Body.Close()
+
+	query := req.URL.Query()
+	if uploadId := query.Get(queryUploadId); uploadId != "" {
+		partNumber, err := strconv.ParseInt(query.Get(queryPartNumber), 10, 64)
+		if err != nil || partNumber < 1 {
+			http.Error(w, "invalid part number", http.StatusBadRequest)
+			return
+		}
+		etag, err := r.uploadPart(key, uploadId, partNumber, req.Body)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		return
+	}
+
+	// 分片上传的请求头不影响上传参数，与 s3 相同只检查整个文件的上传
+	if err := checkUnsignedHeader(req); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	opts, err := uploadOptionsFromHeader(signedHeader(req))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	expires, err := objectExpiresOption(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts = append(opts, expires)
+	if err := r.Upload(req.Context(), key, req.Body, opts...); err != nil {
+		writeError(w, err)
+		return
+	}
+	info, err := r.Stat(req.Context(), key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("ETag", info.ETag)
+}
+
+// getKeyFromUrl 去掉 path 前缀得到 key
+func (r *local) getKeyFromUrl(u *url.URL) (string, bool) {
+	prefix := "/"
+	if p, err := url.Parse(r.path); err == nil && p.Path != "" {
+		prefix = strings.TrimSuffix(p.Path, "/") + "/"
+	}
+	if !strings.HasPrefix(u.Path, prefix) || len(u.Path) == len(prefix) {
+		return "", false
+	}
+	return u.Path[len(prefix):], true
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, oss.ErrNotFound), errors.Is(err, oss.ErrUploadNotFound):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case errors.Is(err, oss.ErrInvalidTags):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, oss.ErrPermission):
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
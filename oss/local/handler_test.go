@@ -0,0 +1,207 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blues120/ias-kit/oss"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) (*local, *httptest.Server) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	store, err := NewLocal(t.TempDir(), srv.URL+"/files", WithSignKey([]byte("secret")))
+	require.NoError(t, err)
+	handler, err := NewHandler(store)
+	require.NoError(t, err)
+	mux.Handle("/files/", handler)
+
+	return store.(*local), srv
+}
+
+func doRequest(t *testing.T, method, url string, body []byte, header http.Header) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	for name := range header {
+		req.Header.Set(name, header.Get(name))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, data
+}
+
+func TestHandler_Download(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestServer(t)
+	key, data := "dir/a b.txt", []byte("0123456789")
+	require.NoError(t, store.Upload(ctx, key, bytes.NewReader(data), oss.WithContentType("text/plain")))
+
+	url, err := store.GenerateTemporaryUrl(ctx, key, time.Minute)
+	require.NoError(t, err)
+	resp, body := doRequest(t, http.MethodGet, url, nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, data, body)
+	require.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	// Range
+	resp, body = doRequest(t, http.MethodGet, url, nil, http.Header{"Range": {"bytes=2-4"}})
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, data[2:5], body)
+
+	// ETag
+	resp, _ = doRequest(t, http.MethodGet, url, nil, http.Header{"If-None-Match": {etag}})
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	// 篡改 key 或过期时间
+	resp, _ = doRequest(t, http.MethodGet, strings.Replace(url, "a%20b", "c", 1), nil, nil)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, _ = doRequest(t, http.MethodGet, strings.Replace(url, "expires=", "expires=1", 1), nil, nil)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// 过期
+	store.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	resp, _ = doRequest(t, http.MethodGet, url, nil, nil)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	store.now = time.Now
+
+	_, err = store.GenerateTemporaryUrl(ctx, key, oss.MaxUrlExpire+time.Second)
+	require.ErrorIs(t, err, oss.ErrExpireTooLong)
+}
+
+func TestHandler_PermanentUrl(t *testing.T) {
+	ctx := context.Background()
+	store, srv := newTestServer(t)
+	require.NoError(t, store.Upload(ctx, "public", bytes.NewReader([]byte("1"))))
+
+	resp, _ := doRequest(t, http.MethodGet, srv.URL+"/files/public", nil, nil)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	url, err := store.GeneratePermanentUrl(ctx, "public")
+	require.NoError(t, err)
+	require.Equal(t, srv.URL+"/files/public", url)
+	resp, body := doRequest(t, http.MethodGet, url, nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []byte("1"), body)
+}
+
+func TestHandler_Upload(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestServer(t)
+	key, data := "upload", []byte("0123456789")
+
+	url, err := store.GenerateUploadUrl(ctx, key, time.Minute, oss.WithContentType("text/plain"), oss.WithTags(map[string]string{"tenant": "a"}))
+	require.NoError(t, err)
+
+	// 请求头与签名不一致
+	resp, _ := doRequest(t, http.MethodPut, url, data, http.Header{"Content-Type": {"text/html"}})
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	header := http.Header{"Content-Type": {"text/plain"}, "X-Amz-Tagging": {"tenant=a"}}
+	resp, _ = doRequest(t, http.MethodPut, url, data, header)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	tags, err := store.GetTags(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"tenant": "a"}, tags)
+	info, err := store.Stat(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, "text/plain", info.ContentType)
+	require.Equal(t, int64(len(data)), info.Size)
+
+	// 签名链接只能用于签名时的请求方法
+	resp, _ = doRequest(t, http.MethodGet, url, nil, nil)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestHandler_UploadExpires(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestServer(t)
+	key := "expires"
+
+	url, err := store.GenerateUploadUrl(ctx, key, time.Minute, oss.WithTTL(time.Hour))
+	require.NoError(t, err)
+	require.Contains(t, url, queryObjectExpires+"=")
+
+	// 过期时间参与签名
+	resp, _ := doRequest(t, http.MethodPut, strings.Replace(url, queryObjectExpires+"=", queryObjectExpires+"=1", 1), []byte("1"), nil)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, _ = doRequest(t, http.MethodPut, url, []byte("1"), nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	exists, err := store.Exists(ctx, key)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	store.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	exists, err = store.Exists(ctx, key)
+	require.NoError(t, err)
+	require.False(t, exists)
+	store.now = time.Now
+}
+
+func TestHandler_UploadUnsignedHeader(t *testing.T) {
+	ctx := context.Background()
+	store, srv := newTestServer(t)
+	key := "unsigned"
+
+	url, err := store.GenerateUploadUrl(ctx, key, time.Minute)
+	require.NoError(t, err)
+	for _, header := range []http.Header{
+		{"X-Amz-Acl": {oss.AclPublicRead}},
+		{"X-Amz-Meta-Owner": {"a"}},
+		{"Content-Type": {"text/html"}},
+		{"Cache-Control": {"max-age=60"}},
+	} {
+		resp, _ := doRequest(t, http.MethodPut, url, []byte("1"), header)
+		require.Equal(t, http.StatusForbidden, resp.StatusCode, header)
+	}
+	exists, err := store.Exists(ctx, key)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	resp, _ := doRequest(t, http.MethodPut, url, []byte("1"), nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	// 未签名的链接不能访问
+	resp, _ = doRequest(t, http.MethodGet, srv.URL+"/files/"+key, nil, nil)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestHandler_UploadPart(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestServer(t)
+	key := "multipart"
+
+	uploadId, err := store.CreateMultipartUpload(ctx, key)
+	require.NoError(t, err)
+	for i, part := range []string{"abc", "def"} {
+		url, err := store.GenerateUploadPartUrl(ctx, key, uploadId, int64(i+1), time.Minute)
+		require.NoError(t, err)
+		// 客户端通常会带上未签名的 Content-Type，不影响分片上传
+		header := http.Header{"Content-Type": []string{"application/octet-stream"}}
+		resp, _ := doRequest(t, http.MethodPut, url, []byte(part), header)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	_, err = store.CompleteMultipartUpload(ctx, key, uploadId, 2)
+	require.NoError(t, err)
+
+	readCloser, err := store.Download(ctx, key)
+	require.NoError(t, err)
+	defer readCloser.Close()
+	actual, err := io.ReadAll(readCloser)
+	require.NoError(t, err)
+	require.Equal(t, []byte("abcdef"), actual)
+}
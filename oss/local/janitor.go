@@ -0,0 +1,118 @@
+package local
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blues120/ias-kit/oss"
+)
+
+// WithJanitor 每隔 interval 删除已过期的文件，过期时间通过 oss.WithExpires 或 oss.WithTTL 设置
+// 开启后须调用 Close 停止后台 goroutine，NewLocal 的返回值实现了 io.Closer
+func WithJanitor(interval time.Duration) Option {
+	return func(r *local) {
+		r.janitorInterval = interval
+	}
+}
+
+// startJanitor 启动定期删除过期文件的 goroutine
+func (r *local) startJanitor() {
+	r.janitorStop = make(chan struct{})
+	r.janitorDone = make(chan struct{})
+	go func() {
+		defer close(r.janitorDone)
+		ticker := time.NewTicker(r.janitorInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.janitorStop:
+				return
+			case <-ticker.C:
+				// 删除失败的文件在下一轮重试
+				_, _ = r.deleteExpired(context.Background())
+			}
+		}
+	}()
+}
+
+// Close 停止 janitor，未开启 janitor 时不做处理，可以多次调用
+func (r *local) Close() error {
+	r.closeOnce.Do(func() {
+		if r.janitorStop != nil {
+			close(r.janitorStop)
+			<-r.janitorDone
+		}
+	})
+	return nil
+}
+
+// deleteExpired 删除所有已过期的文件
+// 过期时间保存在 sidecar 中，只需遍历 sidecar 目录，不必读取文件
+func (r *local) deleteExpired(ctx context.Context) (*oss.DeleteResult, error) {
+	ret := &oss.DeleteResult{
+		Deleted: make([]string, 0),
+		Errors:  make([]*oss.DeleteError, 0),
+	}
+	err := filepath.WalkDir(r.getMetaDir(), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if isNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() || isTempFile(d.Name()) || !strings.HasSuffix(d.Name(), ".json") {
+			return nil
+		}
+
+		// sidecar 可能已被并发删除，读取失败时跳过；是否过期以 expire 中重新读取的为准
+		s, err := readSidecar(p)
+		if err != nil {
+			return nil
+		}
+		key, err := r.checkKey(s.Key)
+		if err != nil {
+			return nil
+		}
+		deleted, err := r.expire(key)
+		if err != nil {
+			ret.Errors = append(ret.Errors, &oss.DeleteError{Key: key, Err: convertError(err)})
+		} else if deleted {
+			ret.Deleted = append(ret.Deleted, key)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// expire 文件已过期时删除，返回是否删除
+// 持有写锁后重新读取 sidecar，避免删除遍历期间被重新上传的文件
+func (r *local) expire(key string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	meta, err := r.readMeta(key)
+	if err != nil {
+		return false, err
+	}
+	if !meta.expired(r.now()) {
+		return false, nil
+	}
+	if err := r.removeLocked(key); err != nil {
+		if !isNotFound(err) {
+			return false, err
+		}
+		// 文件已不存在，清理残留的 sidecar
+		if err := r.removeMeta(key); err != nil {
+			return false, err
+		}
+	}
+	r.pruneParentDirs(key)
+	return true, nil
+}
@@ -2,12 +2,23 @@ package local
 
 import (
 	"context"
+	"crypto/md5"
+	"crypto/rand"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/blues120/ias-kit/oss"
@@ -17,73 +28,818 @@ import (
 type local struct {
 	storePath string
 	path      string
+
+	// stagingDir 分片上传的临时目录，每个上传使用以 uploadId 命名的子目录
+	stagingDir string
+
+	// signKey 临时链接的签名密钥
+	signKey []byte
+	now     func() time.Time
+
+	// pruneEmptyDirs Delete 后是否删除因此变为空的上级目录
+	pruneEmptyDirs bool
+
+	// versioning 是否保留被覆盖或删除的版本
+	versioning bool
+
+	// mu 写入文件及 sidecar 时持有写锁，用于条件上传及多版本
+	// 读取时持有读锁，保证读到的文件与 sidecar 一致
+	mu sync.RWMutex
+
+	// janitorInterval 删除过期文件的间隔，为 0 时不启动 janitor
+	janitorInterval time.Duration
+	janitorStop     chan struct{}
+	janitorDone     chan struct{}
+	closeOnce       sync.Once
+}
+
+type Option func(*local)
+
+// WithStagingDir 设置分片上传的临时目录，默认为 storePath 下的隐藏目录
+// 临时目录应与 storePath 在同一文件系统，且不能被多个 storePath 不同的实例共用
+// 临时目录位于 storePath 下时，其中的文件不会被列举，对应的 key 也不能使用
+func WithStagingDir(stagingDir string) Option {
+	return func(r *local) {
+		r.stagingDir = stagingDir
+	}
+}
+
+// WithSignKey 设置临时链接的签名密钥
+// 未设置时每次启动随机生成，重启后之前生成的链接失效，多实例部署时须设置相同的密钥
+func WithSignKey(signKey []byte) Option {
+	return func(r *local) {
+		r.signKey = signKey
+	}
 }
 
-func NewLocal(storePath, path string) (oss.Oss, error) {
+// WithPruneEmptyDirs Delete 后删除因此变为空的上级目录，避免目录树中残留大量空目录
+// DeleteMany 及 DeletePrefix 总是会清理空目录
+func WithPruneEmptyDirs() Option {
+	return func(r *local) {
+		r.pruneEmptyDirs = true
+	}
+}
+
+// WithVersioning 开启多版本，被覆盖或删除的版本保存在 storePath 下的隐藏目录中
+// 历史版本不会自动清理，须通过 DeleteVersion 删除
+func WithVersioning() Option {
+	return func(r *local) {
+		r.versioning = true
+	}
+}
+
+// NewLocal 创建本地存储，返回值实现了 io.Closer，用于停止 WithJanitor 启动的 goroutine
+// storePath 文件存储目录
+// path 链接前缀，如 "http://example.com/files"，NewHandler 返回的 http.Handler 须挂载在此前缀下
+func NewLocal(storePath, path string, opts ...Option) (oss.Oss, error) {
+	storePath = filepath.Clean(storePath)
 	if err := os.MkdirAll(storePath, os.ModePerm); err != nil {
 		return nil, err
 	}
-	return &local{storePath: storePath, path: path}, nil
+
+	r := &local{
+		storePath:  storePath,
+		path:       path,
+		stagingDir: filepath.Join(storePath, internalDir, "multipart"),
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.stagingDir = filepath.Clean(r.stagingDir)
+	if err := os.MkdirAll(r.stagingDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	if len(r.signKey) == 0 {
+		r.signKey = make([]byte, 32)
+		if _, err := rand.Read(r.signKey); err != nil {
+			return nil, err
+		}
+	}
+	if r.janitorInterval > 0 {
+		r.startJanitor()
+	}
+	return r, nil
 }
 
-func (r *local) Upload(ctx context.Context, key string, reader io.Reader) error {
+func (r *local) Upload(ctx context.Context, key string, reader io.Reader, opts ...oss.UploadOption) (err error) {
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return err
+	}
+	o := oss.NewUploadOptions(opts...)
+	if err := oss.ValidateTags(o.Tags); err != nil {
+		return err
+	}
+	// 先检查一次条件，避免条件不满足时仍读取整个 reader
+	if err := r.checkPrecondition(key, o.Precondition); err != nil {
+		return err
+	}
 	p := r.getSavePath(key)
+	h := md5.New()
+	tmp, err := writeParentTempFile(p, func(w io.Writer) error {
+		_, err := io.Copy(io.MultiWriter(w, h), reader)
+		return err
+	})
+	if err != nil {
+		return err
+	}
 
-	out, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_SYNC, os.ModePerm)
+	meta := newObjectMeta(o)
+	meta.ETag = oss.MD5ETag(h.Sum(nil))
+	return r.commit(key, tmp, meta, o.Precondition)
+}
+
+// commit 将临时文件重命名为 key 对应的文件并写入 sidecar
+func (r *local) commit(key, tmp string, meta *objectMeta, cond oss.Precondition) error {
+	err := r.replace(key, meta, cond, func(dst string) error {
+		return commitFile(tmp, dst)
+	})
 	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
+
+// replace 通过 place 写入 key 对应的文件后写入 sidecar，开启多版本时先归档当前版本
+// 持有写锁，保证条件检查与替换文件之间不会有其他写入，仅对同一进程内的写入有效
+func (r *local) replace(key string, meta *objectMeta, cond oss.Precondition, place func(dst string) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.replaceLocked(key, meta, cond, place)
+}
+
+// replaceLocked 同 replace，调用方须持有写锁
+func (r *local) replaceLocked(key string, meta *objectMeta, cond oss.Precondition, place func(dst string) error) error {
+	if err := r.checkPrecondition(key, cond); err != nil {
 		return err
 	}
-	defer out.Close()
+	meta.VersionId = ""
+	if r.versioning {
+		if err := r.archiveCurrent(key); err != nil {
+			return err
+		}
+		meta.VersionId = newVersionId()
+	}
+	return r.placeObject(key, meta, place)
+}
 
-	if _, err := io.Copy(out, reader); err != nil {
+// placeObject 通过 place 写入 key 对应的文件并替换 sidecar，调用方须持有写锁
+// sidecar 先写入临时文件，替换文件后只需重命名；重命名失败时恢复原文件，避免新文件使用旧的 sidecar
+// 进程在两次重命名之间崩溃时仍可能不一致，留下的备份文件以 tempPrefix 开头，不会被列举
+func (r *local) placeObject(key string, meta *objectMeta, place func(dst string) error) error {
+	metaTmp, err := r.writeMetaTemp(key, meta)
+	if err != nil {
 		return err
 	}
+	discard := func(p string) {
+		if p != "" {
+			_ = os.Remove(p)
+		}
+	}
+
+	p := r.getSavePath(key)
+	backup, err := backupFile(p)
+	if err != nil {
+		discard(metaTmp)
+		return err
+	}
+	if err := place(p); err != nil {
+		discard(metaTmp)
+		discard(backup)
+		return err
+	}
+	if err := r.commitMeta(key, metaTmp); err != nil {
+		discard(metaTmp)
+		_ = restoreFile(backup, p)
+		return err
+	}
+	discard(backup)
 	return nil
 }
 
-func (r *local) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+// removeLocked 删除 key 对应的文件及 sidecar，开启多版本时先归档当前版本并添加删除标记
+// 调用方须持有写锁
+// 嵌套 key 的上级目录不是文件，视为不存在，不会被删除
+func (r *local) removeLocked(key string) error {
 	p := r.getSavePath(key)
-	return os.Open(p)
+	if _, err := statObject(p); err != nil {
+		return err
+	}
+	if r.versioning {
+		if err := r.archiveCurrent(key); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(p); err != nil {
+		return err
+	}
+	if err := r.removeMeta(key); err != nil {
+		return err
+	}
+	if r.versioning {
+		return r.writeDeleteMarker(key)
+	}
+	return nil
 }
 
-func (r *local) Delete(ctx context.Context, key string) error {
-	p := r.getSavePath(key)
-	return os.Remove(p)
+// checkPrecondition 根据文件当前的 ETag 检查条件
+func (r *local) checkPrecondition(key string, cond oss.Precondition) error {
+	if cond.IsZero() {
+		return nil
+	}
+	etag, exists, err := r.currentETag(key)
+	if err != nil {
+		return err
+	}
+	return cond.Check(etag, exists)
 }
 
-// Exists 判断本地文件是否存在
-func (r *local) Exists(ctx context.Context, key string) (bool, error) {
-	// 获取绝对路径
+// statKey 获取 key 对应文件的信息及元数据，已过期但尚未被 janitor 删除的文件视为不存在
+// 读取对象的方法都应使用 statKey 或 openKey，删除时使用 statObject 以便清理过期文件
+func (r *local) statKey(key string) (fs.FileInfo, *objectMeta, error) {
 	p := r.getSavePath(key)
+	info, err := statObject(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta, err := r.readMeta(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if meta.expired(r.now()) {
+		return nil, nil, &fs.PathError{Op: "stat", Path: p, Err: fs.ErrNotExist}
+	}
+	return info, meta, nil
+}
 
-	_, err := os.Stat(p)
+// openKey 打开 key 对应的文件，规则同 statKey
+func (r *local) openKey(key string) (*os.File, error) {
+	if _, _, err := r.statKey(key); err != nil {
+		return nil, err
+	}
+	return os.Open(r.getSavePath(key))
+}
+
+// currentETag 获取文件当前的 ETag，文件不存在时 exists 为 false
+func (r *local) currentETag(key string) (etag string, exists bool, err error) {
+	_, meta, err := r.statKey(key)
+	if err != nil {
+		if isNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	if meta.ETag != "" {
+		return meta.ETag, true, nil
+	}
+	etag, err = fileETag(r.getSavePath(key))
+	if err != nil {
+		return "", false, err
+	}
+	return etag, true, nil
+}
+
+func (r *local) Download(ctx context.Context, key string, opts ...oss.DownloadOption) (_ io.ReadCloser, err error) {
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return nil, err
+	}
+	o := oss.NewDownloadOptions(opts...)
+
+	// 持有读锁，保证打开的文件与检查条件时的 ETag 一致
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if err := r.checkPrecondition(key, o.Precondition); err != nil {
+		return nil, err
+	}
+	return r.openKey(key)
+}
+
+func (r *local) DownloadRange(ctx context.Context, key string, offset, length int64) (_ io.ReadCloser, err error) {
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	f, err := r.openKey(key)
+	r.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if offset < 0 || offset >= info.Size() {
+		f.Close()
+		return nil, oss.ErrInvalidRange
+	}
+	// 不使用 offset+length 比较，避免 length 很大时溢出
+	if length <= 0 || length > info.Size()-offset {
+		length = info.Size() - offset
+	}
+
+	return &rangeReader{
+		Reader: io.NewSectionReader(f, offset, length),
+		Closer: f,
+	}, nil
+}
+
+// rangeReader 读取文件的一部分，关闭时关闭文件
+type rangeReader struct {
+	io.Reader
+	io.Closer
+}
+
+// Delete 与 s3 相同，文件不存在时不返回错误
+func (r *local) Delete(ctx context.Context, key string) (err error) {
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return err
+	}
+	// 持有写锁清理目录，避免删除其他写入刚创建的上级目录
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.removeLocked(key); err != nil && !isNotFound(err) {
+		return err
+	}
+
+	if r.pruneEmptyDirs {
+		r.pruneParentDirs(key)
+	}
+	return nil
+}
+
+// DeleteMany 逐个删除文件，并清理因此变为空的目录
+func (r *local) DeleteMany(ctx context.Context, keys []string) (*oss.DeleteResult, error) {
+	ret := &oss.DeleteResult{
+		Deleted: make([]string, 0, len(keys)),
+		Errors:  make([]*oss.DeleteError, 0),
+	}
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return ret, err
+		}
+
+		if err := r.deleteOne(key); err != nil {
+			ret.Errors = append(ret.Errors, &oss.DeleteError{Key: key, Err: convertError(err)})
+			continue
+		}
+		ret.Deleted = append(ret.Deleted, key)
+	}
+	return ret, nil
+}
+
+// deleteOne 删除单个文件及其 sidecar，文件不存在时视为删除成功
+func (r *local) deleteOne(key string) error {
+	key, err := r.checkKey(key)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.removeLocked(key); err != nil && !isNotFound(err) {
+		return err
+	}
+	// 文件不存在时可能残留 sidecar
+	if err := r.removeMeta(key); err != nil {
+		return err
+	}
+
+	r.pruneParentDirs(key)
+	return nil
+}
+
+// DeletePrefix 删除以 prefix 开头的所有文件
+func (r *local) DeletePrefix(ctx context.Context, prefix string) (_ *oss.DeleteResult, err error) {
+	defer wrapError(&err)
+	if prefix == "" {
+		return nil, oss.ErrEmptyPrefix
+	}
+	if err := r.checkPrefix(prefix); err != nil {
+		return nil, err
+	}
+
+	objects, err := r.walk(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		keys = append(keys, obj.Key)
+	}
+	return r.DeleteMany(ctx, keys)
+}
+
+// pruneParentDirs 删除 key 因删除而变为空的上级目录，sidecar 不分目录存放，无需清理
+// 调用方须持有写锁；不持有写锁写入的 Upload 等通过 writeParentTempFile 重建被清理的目录
+func (r *local) pruneParentDirs(key string) {
+	pruneEmptyDirs(filepath.Dir(r.getSavePath(key)), r.storePath)
+}
+
+// makeParentDir 创建 p 的上级目录，使嵌套的 key 无需预先创建目录
+// 上级路径是已存在的文件时返回 oss.ErrAlreadyExists，与 key 是已存在的目录时一致，
+// 避免 convertError 将写入时的 ENOTDIR 视为不存在
+func makeParentDir(p string) error {
+	err := os.MkdirAll(filepath.Dir(p), os.ModePerm)
+	if errors.Is(err, syscall.ENOTDIR) {
+		return &oss.Error{Kind: oss.ErrAlreadyExists, Err: err}
+	}
+	return err
+}
+
+// writeParentTempFile 创建 p 的上级目录并在其中写入临时文件，返回临时文件路径
+// 不持有写锁，并发的删除可能在创建目录后清理空目录，此时重新创建一次
+func writeParentTempFile(p string, write func(w io.Writer) error) (string, error) {
+	dir := filepath.Dir(p)
+	for retried := false; ; retried = true {
+		if err := makeParentDir(p); err != nil {
+			return "", err
+		}
+		tmp, err := writeTempFile(dir, write)
+		if err == nil || retried || !errors.Is(err, fs.ErrNotExist) {
+			return tmp, err
+		}
+		// 目录仍存在时是 write 本身的错误，不再重试
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return "", err
+		}
+	}
+}
+
+// pruneEmptyDirs 自 dir 向上逐级删除空目录，直到 root 或非空目录为止
+func pruneEmptyDirs(dir, root string) {
+	for dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// Copy 同一文件系统内使用硬链接，不支持时复制文件内容
+func (r *local) Copy(ctx context.Context, srcKey, dstKey string) (err error) {
+	defer wrapError(&err)
+	srcKey, dstKey, err = r.checkKeys(srcKey, dstKey)
+	if err != nil {
+		return err
+	}
+	src, dst := r.getSavePath(srcKey), r.getSavePath(dstKey)
+
+	// 持有写锁，保证复制的文件与 sidecar 一致
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, meta, err := r.statKey(srcKey)
+	if err != nil {
+		return err
+	}
+	if src == dst {
+		return nil
+	}
+	if err := makeParentDir(dst); err != nil {
+		return err
+	}
+	return r.replaceLocked(dstKey, meta, oss.Precondition{}, func(dst string) error {
+		return linkOrCopyFile(src, dst)
+	})
+}
+
+// Move 同一文件系统内使用硬链接后删除源文件，不支持硬链接时复制文件内容
+// 开启多版本时与 s3 相同，复制后删除源文件，源文件保留历史版本
+func (r *local) Move(ctx context.Context, srcKey, dstKey string) (err error) {
+	defer wrapError(&err)
+	srcKey, dstKey, err = r.checkKeys(srcKey, dstKey)
+	if err != nil {
+		return err
+	}
+	src, dst := r.getSavePath(srcKey), r.getSavePath(dstKey)
+	if src == dst {
+		_, err := r.Stat(ctx, srcKey)
+		return err
+	}
+	if r.versioning {
+		if err := r.Copy(ctx, srcKey, dstKey); err != nil {
+			return err
+		}
+		return r.Delete(ctx, srcKey)
+	}
+
+	// 先链接再删除源文件，写入 sidecar 失败时恢复目标文件而不会丢失源文件
+	// 嵌套 key 的上级目录不是文件，不能整个目录移动
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, meta, err := r.statKey(srcKey)
+	if err != nil {
+		return err
+	}
+	if err := makeParentDir(dst); err != nil {
+		return err
+	}
+	err = r.replaceLocked(dstKey, meta, oss.Precondition{}, func(dst string) error {
+		return linkOrCopyFile(src, dst)
+	})
+	if err != nil {
+		return err
+	}
+	if err := r.removeLocked(srcKey); err != nil {
+		return err
+	}
+
+	if r.pruneEmptyDirs {
+		r.pruneParentDirs(srcKey)
+	}
+	return nil
+}
+
+// Exists 判断本地文件是否存在
+func (r *local) Exists(ctx context.Context, key string) (_ bool, err error) {
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	_, _, err = r.statKey(key)
+	r.mu.RUnlock()
 	if err == nil {
 		return true, nil
 	}
 
-	if os.IsNotExist(err) {
-		//如果返回的错误类型使用os.isNotExist()判断为true，说明文件或者文件夹不存在
+	if isNotFound(err) {
+		//如果返回的错误类型使用isNotFound()判断为true，说明文件或者文件夹不存在
 		return false, nil
 	}
 
 	return false, err
 }
 
-func (r *local) GenerateUrl(ctx context.Context, key string, expire time.Duration) (string, error) {
-	//name := r.getMD5Name(key)
-	if r.path == "" {
-		return path.Join(r.storePath, key), nil
+// Stat 文件大小及修改时间取自文件系统，其余信息取自 sidecar
+func (r *local) Stat(ctx context.Context, key string) (_ *oss.ObjectInfo, err error) {
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	info, meta, err := r.statKey(key)
+	r.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if meta.ETag == "" {
+		if info, meta, err = r.fillETag(key); err != nil {
+			return nil, err
+		}
+	}
+	contentType := meta.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return &oss.ObjectInfo{
+		Key:                key,
+		Size:               info.Size(),
+		ETag:               meta.ETag,
+		LastModified:       info.ModTime(),
+		ContentType:        contentType,
+		ContentDisposition: meta.ContentDisposition,
+		CacheControl:       meta.CacheControl,
+		ContentEncoding:    meta.ContentEncoding,
+		Metadata:           meta.Metadata,
+		VersionId:          meta.VersionId,
+	}, nil
+}
+
+// fillETag 不是通过 Upload 写入的文件没有 sidecar，计算 ETag 后保存
+func (r *local) fillETag(key string) (fs.FileInfo, *objectMeta, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 获取锁期间文件可能已被替换，重新读取
+	info, meta, err := r.statKey(key)
+	if err != nil || meta.ETag != "" {
+		return info, meta, err
+	}
+	if meta.ETag, err = fileETag(r.getSavePath(key)); err != nil {
+		return nil, nil, err
+	}
+	if err := r.writeMeta(key, meta); err != nil {
+		return nil, nil, err
+	}
+	return info, meta, nil
+}
+
+// GetTags 标签保存在 sidecar 中
+func (r *local) GetTags(ctx context.Context, key string) (_ map[string]string, err error) {
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	_, meta, err := r.statKey(key)
+	r.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(meta.Tags))
+	for k, v := range meta.Tags {
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+// SetTags 与 s3 相同，修改标签不会生成新的版本
+func (r *local) SetTags(ctx context.Context, key string, tags map[string]string) (err error) {
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return err
+	}
+	if err := oss.ValidateTags(tags); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, meta, err := r.statKey(key)
+	if err != nil {
+		return err
+	}
+	meta.Tags = tags
+	if len(tags) == 0 {
+		meta.Tags = nil
+	}
+	return r.writeMeta(key, meta)
+}
+
+// List 遍历 storePath 按前缀列举文件
+// ContinuationToken 为上一页最后返回的 key 或公共前缀
+func (r *local) List(ctx context.Context, prefix string, opts *oss.ListOptions) (_ *oss.ListResult, err error) {
+	defer wrapError(&err)
+	if err := r.checkPrefix(prefix); err != nil {
+		return nil, err
+	}
+	objects, err := r.walk(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return oss.PaginateObjects(objects, prefix, opts), nil
+}
+
+// walk 获取 storePath 下以 prefix 开头的所有文件，按 key 排序，跳过已过期的文件
+func (r *local) walk(ctx context.Context, prefix string) ([]*oss.ObjectInfo, error) {
+	// 只需遍历 prefix 中最后一个 "/" 之前的目录
+	root := r.storePath
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		root = r.getSavePath(prefix[:i])
+	}
+
+	ret := make([]*oss.ObjectInfo, 0)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// 内部目录及分片目录可能是遍历的起点，不能只比较目录本身
+		if r.isInternalPath(p) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || isTempFile(d.Name()) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(r.storePath, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		// 列举时不计算 ETag，只返回 sidecar 中已保存的值
+		r.mu.RLock()
+		info, err := d.Info()
+		if err != nil {
+			r.mu.RUnlock()
+			return err
+		}
+		meta, err := r.readMeta(key)
+		r.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+		if meta.expired(r.now()) {
+			return nil
+		}
+		ret = append(ret, &oss.ObjectInfo{
+			Key:          key,
+			Size:         info.Size(),
+			ETag:         meta.ETag,
+			LastModified: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return path.Join(r.path, key), nil
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Key < ret[j].Key
+	})
+	return ret, nil
 }
 
+func (r *local) GenerateUrl(ctx context.Context, key string, expire time.Duration) (string, error) {
+	key, err := r.checkKey(key)
+	if err != nil {
+		return "", err
+	}
+	return r.signedUrl(http.MethodGet, key, expire, nil, nil), nil
+}
+
+// GenerateTemporaryUrl 生成带签名及过期时间的链接，由 NewHandler 返回的 http.Handler 校验
 func (r *local) GenerateTemporaryUrl(ctx context.Context, key string, expire time.Duration) (string, error) {
-	return r.getSavePath(key), nil
+	key, err := r.checkKey(key)
+	if err != nil {
+		return "", err
+	}
+	// 过期时间最长7天
+	if expire > oss.MaxUrlExpire {
+		return "", oss.ErrExpireTooLong
+	}
+	return r.signedUrl(http.MethodGet, key, expire, nil, nil), nil
+}
+
+// GeneratePermanentUrl 与 s3 相同，将文件设为公开读后返回不带签名的链接
+func (r *local) GeneratePermanentUrl(ctx context.Context, key string) (_ string, err error) {
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	// 与 SetTags 相同，持有写锁，避免覆盖并发写入的 sidecar
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, meta, err := r.statKey(key)
+	if err != nil {
+		return "", err
+	}
+	meta.ACL = oss.AclPublicRead
+	if err := r.writeMeta(key, meta); err != nil {
+		return "", err
+	}
+	return r.getUrl(key), nil
 }
 
-func (r *local) GeneratePermanentUrl(ctx context.Context, key string) (string, error) {
-	return r.getSavePath(key), nil
+func (r *local) GenerateUploadUrl(ctx context.Context, key string, expire time.Duration, opts ...oss.UploadOption) (string, error) {
+	key, err := r.checkKey(key)
+	if err != nil {
+		return "", err
+	}
+	if expire > oss.MaxUrlExpire {
+		return "", oss.ErrExpireTooLong
+	}
+	o := oss.NewUploadOptions(opts...)
+	if err := oss.ValidateTags(o.Tags); err != nil {
+		return "", err
+	}
+	header := uploadHeader(o)
+	return r.signedUrl(http.MethodPut, key, expire, objectExpiresQuery(o), header), nil
+}
+
+func (r *local) GenerateUploadPartUrl(ctx context.Context, key, uploadId string, partNumber int64, expire time.Duration) (string, error) {
+	key, err := r.checkKey(key)
+	if err != nil {
+		return "", err
+	}
+	if expire > oss.MaxUrlExpire {
+		return "", oss.ErrExpireTooLong
+	}
+	query := url.Values{}
+	query.Set(queryUploadId, uploadId)
+	query.Set(queryPartNumber, strconv.FormatInt(partNumber, 10))
+	return r.signedUrl(http.MethodPut, key, expire, query, nil), nil
 }
 
 // // getMD5Name 获取md5格式的文件名
@@ -100,60 +856,160 @@ func (r *local) GeneratePermanentUrl(ctx context.Context, key string) (string, e
 // 	return name + ext
 // }
 
-// getSavePath 获取存储路径
+// checkKey 校验并规范化 key，规则见 oss.NormalizeKey
+// internalDir 及 tempPrefix 开头的文件名为内部保留，不能作为 key 使用
+func (r *local) checkKey(key string) (string, error) {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return "", err
+	}
+	if key == internalDir || strings.HasPrefix(key, internalDir+"/") {
+		return "", fmt.Errorf("%w: %q is reserved", oss.ErrInvalidKey, key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if isTempFile(segment) {
+			return "", fmt.Errorf("%w: %q is reserved", oss.ErrInvalidKey, key)
+		}
+	}
+	if r.isInternalPath(r.getSavePath(key)) {
+		return "", fmt.Errorf("%w: %q is reserved", oss.ErrInvalidKey, key)
+	}
+	return key, nil
+}
+
+// checkPrefix 校验列举、删除的前缀，规则见 oss.ValidatePrefix
+// 与 checkKey 相同，不能以 internalDir 开头
+func (r *local) checkPrefix(prefix string) error {
+	if err := oss.ValidatePrefix(prefix); err != nil {
+		return err
+	}
+	if prefix == internalDir || strings.HasPrefix(prefix, internalDir+"/") {
+		return fmt.Errorf("%w: %q is reserved", oss.ErrInvalidKey, prefix)
+	}
+	return nil
+}
+
+// isInternalPath p 是否为 internalDir 或分片目录及其中的文件
+// 分片目录可以通过 WithStagingDir 设置在 storePath 下的任意位置
+func (r *local) isInternalPath(p string) bool {
+	for _, dir := range []string{filepath.Join(r.storePath, internalDir), r.stagingDir} {
+		if p == dir || strings.HasPrefix(p, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkKeys 校验复制、移动的源及目标 key
+func (r *local) checkKeys(srcKey, dstKey string) (string, string, error) {
+	srcKey, err := r.checkKey(srcKey)
+	if err != nil {
+		return "", "", err
+	}
+	dstKey, err = r.checkKey(dstKey)
+	if err != nil {
+		return "", "", err
+	}
+	return srcKey, dstKey, nil
+}
+
+// getSavePath 获取存储路径，key 须先经过 checkKey 校验
 func (r *local) getSavePath(key string) string {
 	//name := r.getMD5Name(key)
 	return path.Join(r.storePath, key)
 }
 
 // 分片上传每个分片最小为 5MB，如果不适用需要用普通上传
-func (r *local) CreateMultipartUpload(ctx context.Context, key string) (uploadId string, err error) {
-	return uuid.New().String(), nil
+// 上传参数保存在分片目录中，完成分片上传时写入 sidecar
+func (r *local) CreateMultipartUpload(ctx context.Context, key string, opts ...oss.UploadOption) (uploadId string, err error) {
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return "", err
+	}
+	o := oss.NewUploadOptions(opts...)
+	if err := oss.ValidateTags(o.Tags); err != nil {
+		return "", err
+	}
+	uploadId = uuid.New().String()
+
+	if err = os.MkdirAll(r.getUploadDir(uploadId), os.ModePerm); err != nil {
+		return "", err
+	}
+	upload := &multipartUpload{
+		Key:       key,
+		Initiated: r.now(),
+		Meta:      newObjectMeta(o),
+	}
+	if err = r.writeUpload(uploadId, upload); err != nil {
+		return "", err
+	}
+	return uploadId, nil
 }
 
 func (r *local) UploadPart(ctx context.Context, key, uploadId string, partNumber int64, reader io.ReadSeeker) (etag string, err error) {
-
-	// 创建本地文件
-	targetFileName := getUploadTmpPath(uploadId, partNumber)
-	err = os.MkdirAll(filepath.Dir(targetFileName), os.ModePerm)
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
 	if err != nil {
 		return "", err
 	}
-	localFile, err := os.Create(targetFileName)
+	// 将输入参数的指针重新定位到起始位置
+	_, err = reader.Seek(0, io.SeekStart)
 	if err != nil {
 		return "", err
 	}
-	defer localFile.Close()
 
-	// 将输入参数的指针重新定位到起始位置
-	_, err = reader.Seek(0, io.SeekStart)
-	if err != nil {
+	return r.uploadPart(key, uploadId, partNumber, reader)
+}
+
+// uploadPart 将分片写入临时文件，供 UploadPart 及 http 上传分片使用
+func (r *local) uploadPart(key, uploadId string, partNumber int64, reader io.Reader) (etag string, err error) {
+	if _, err = r.readUpload(key, uploadId); err != nil {
 		return "", err
 	}
+	if partNumber < 1 || partNumber > maxPartNumber {
+		return "", fmt.Errorf("the part number must be between 1 and %d", maxPartNumber)
+	}
 
-	// 读取数据并写入文件
-	_, err = io.Copy(localFile, reader)
+	// 读取数据并写入文件，上传失败时不会留下不完整的分片
+	h := md5.New()
+	err = writeFileAtomic(r.getUploadPartPath(uploadId, partNumber), func(w io.Writer) error {
+		_, err := io.Copy(io.MultiWriter(w, h), reader)
+		return err
+	})
 	if err != nil {
-		err = os.RemoveAll(getUploadTmpPath(uploadId, partNumber))
 		return "", err
 	}
 
-	return "", nil
+	etag = oss.MD5ETag(h.Sum(nil))
+	if err := r.writePartETag(uploadId, partNumber, etag); err != nil {
+		return "", err
+	}
+	return etag, nil
 }
 
-func (r *local) AbortMultipartUpload(ctx context.Context, key, uploadId string) error {
-	err := os.RemoveAll("/tmp/" + uploadId)
-	return err
+func (r *local) AbortMultipartUpload(ctx context.Context, key, uploadId string) (err error) {
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return err
+	}
+	if _, err := r.readUpload(key, uploadId); err != nil {
+		return err
+	}
+	return os.RemoveAll(r.getUploadDir(uploadId))
 }
 
 func (r *local) CompleteMultipartUpload(ctx context.Context, key, uploadId string, partsNum int64) (etag string, err error) {
-	// 生成最终文件
-	targetPath := r.getSavePath(key)
-	finalFile, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return "", err
+	}
+	upload, err := r.readUpload(key, uploadId)
 	if err != nil {
 		return "", err
 	}
-	defer finalFile.Close()
 
 	// 获取所有分片
 	parts, err := r.ListParts(ctx, key, uploadId, partsNum)
@@ -165,28 +1021,56 @@ func (r *local) CompleteMultipartUpload(ctx context.Context, key, uploadId strin
 	}
 
 	// 将所有分片写入最终文件
-	for _, part := range parts {
-		partPath := getUploadTmpPath(uploadId, part.PartNumber)
-		tempFile, err := os.Open(partPath)
-		if err != nil {
-			return "", err
-		}
+	p := r.getSavePath(key)
+	tmp, err := writeParentTempFile(p, func(w io.Writer) error {
+		for _, part := range parts {
+			partPath := r.getUploadPartPath(uploadId, part.PartNumber)
+			tempFile, err := os.Open(partPath)
+			if err != nil {
+				return err
+			}
 
-		_, err = io.Copy(finalFile, tempFile)
-		tempFile.Close()
-		if err != nil {
-			return "", err
+			_, err = io.Copy(w, tempFile)
+			tempFile.Close()
+			if err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// 写入上传参数
+	partETags := make([]string, 0, len(parts))
+	for _, part := range parts {
+		partETags = append(partETags, part.ETag)
+	}
+	if upload.Meta.ETag, err = oss.MultipartETag(partETags); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	if err := r.commit(key, tmp, upload.Meta, oss.Precondition{}); err != nil {
+		return "", err
 	}
 
 	// 删除临时文件
-	err = os.RemoveAll("/tmp/" + uploadId)
+	err = os.RemoveAll(r.getUploadDir(uploadId))
 
-	return "", err
+	return upload.Meta.ETag, err
 }
 
 // 循环获取所有分片
 func (r *local) ListParts(ctx context.Context, key, uploadId string, maxParts int64) (parts []*oss.CompletedPart, err error) {
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.readUpload(key, uploadId); err != nil {
+		return nil, err
+	}
 
 	ret := make([]*oss.CompletedPart, 0)
 
@@ -195,19 +1079,19 @@ func (r *local) ListParts(ctx context.Context, key, uploadId string, maxParts in
 	}
 
 	for i := 1; i <= int(maxParts); i++ {
-		filepath := getUploadTmpPath(uploadId, int64(i))
+		filepath := r.getUploadPartPath(uploadId, int64(i))
 		if _, err := os.Stat(filepath); err != nil {
 			continue
 		}
+		etag, err := r.readPartETag(uploadId, int64(i))
+		if err != nil {
+			return nil, err
+		}
 		ret = append(ret, &oss.CompletedPart{
 			PartNumber: int64(i),
-			ETag:       "",
+			ETag:       etag,
 		})
 	}
 
 	return ret, nil
 }
-
-func getUploadTmpPath(uploadId string, partNumber int64) string {
-	return "/tmp/" + uploadId + "/part_" + strconv.Itoa(int(partNumber)) + ".tmp"
-}
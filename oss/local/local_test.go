@@ -3,11 +3,22 @@ package local
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
 	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/blues120/ias-kit/oss"
+	"github.com/blues120/ias-kit/oss/osstest"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -15,9 +26,10 @@ import (
 type LocalTestSuite struct {
 	suite.Suite
 
-	local   oss.Oss
-	ossKey  string
-	ossData []byte
+	local     oss.Oss
+	storePath string
+	ossKey    string
+	ossData   []byte
 }
 
 func (s *LocalTestSuite) SetupTest() {
@@ -32,6 +44,7 @@ func TestLocalTestSuite(t *testing.T) {
 	local, err := NewLocal(tempDir, "")
 	require.NoError(t, err)
 	ts.local = local
+	ts.storePath = tempDir
 
 	suite.Run(t, ts)
 }
@@ -41,6 +54,47 @@ func (s *LocalTestSuite) TestLocal_Upload() {
 	require.NoError(s.T(), err)
 }
 
+func (s *LocalTestSuite) TestLocal_UploadFailed() {
+	s.TestLocal_Upload()
+
+	// 上传失败时保留原文件，且不留下临时文件
+	reader := io.MultiReader(bytes.NewReader([]byte("partial")), iotest.ErrReader(errors.New("broken pipe")))
+	err := s.local.Upload(context.Background(), s.ossKey, reader)
+	require.Error(s.T(), err)
+	s.requireContent(s.ossKey, s.ossData)
+
+	entries, err := os.ReadDir(s.storePath)
+	require.NoError(s.T(), err)
+	for _, entry := range entries {
+		require.False(s.T(), isTempFile(entry.Name()), entry.Name())
+	}
+}
+
+func (s *LocalTestSuite) TestLocal_UploadMetaFailed() {
+	ctx := context.Background()
+	require.NoError(s.T(), s.local.Upload(ctx, "meta-failed", bytes.NewReader([]byte("old"))))
+
+	// sidecar 被目录占用，写入 sidecar 失败时恢复原文件，且不留下临时文件
+	metaPath := s.local.(*local).getMetaPath("meta-failed")
+	require.NoError(s.T(), os.Remove(metaPath))
+	require.NoError(s.T(), os.MkdirAll(filepath.Join(metaPath, "x"), os.ModePerm))
+	err := s.local.Upload(ctx, "meta-failed", bytes.NewReader([]byte("new")), oss.WithContentType("text/plain"))
+	require.Error(s.T(), err)
+	require.NoError(s.T(), os.RemoveAll(metaPath))
+	s.requireContent("meta-failed", []byte("old"))
+
+	entries, err := os.ReadDir(s.storePath)
+	require.NoError(s.T(), err)
+	for _, entry := range entries {
+		require.False(s.T(), isTempFile(entry.Name()), entry.Name())
+	}
+	entries, err = os.ReadDir(filepath.Dir(metaPath))
+	require.NoError(s.T(), err)
+	for _, entry := range entries {
+		require.False(s.T(), isTempFile(entry.Name()), entry.Name())
+	}
+}
+
 func (s *LocalTestSuite) TestLocal_Download() {
 	s.TestLocal_Upload()
 
@@ -53,6 +107,114 @@ func (s *LocalTestSuite) TestLocal_Download() {
 	require.Equal(s.T(), s.ossData, actual)
 }
 
+func (s *LocalTestSuite) TestLocal_DownloadRange() {
+	s.TestLocal_Upload()
+
+	cases := []struct {
+		offset, length int64
+		expected       []byte
+	}{
+		{0, 0, s.ossData},
+		{1, 1, s.ossData[1:2]},
+		{1, 0, s.ossData[1:]},
+		{1, 100, s.ossData[1:]},
+	}
+	for _, c := range cases {
+		readCloser, err := s.local.DownloadRange(context.Background(), s.ossKey, c.offset, c.length)
+		require.NoError(s.T(), err)
+		actual, err := io.ReadAll(readCloser)
+		readCloser.Close()
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), c.expected, actual)
+	}
+
+	_, err := s.local.DownloadRange(context.Background(), s.ossKey, int64(len(s.ossData)), 1)
+	require.ErrorIs(s.T(), err, oss.ErrInvalidRange)
+}
+
+func (s *LocalTestSuite) TestLocal_CopyAndMove() {
+	ctx := context.Background()
+	err := s.local.Upload(ctx, s.ossKey, bytes.NewReader(s.ossData), oss.WithContentType("text/plain"))
+	require.NoError(s.T(), err)
+
+	copyKey, moveKey := s.ossKey+"-copy", s.ossKey+"-move"
+	require.NoError(s.T(), s.local.Copy(ctx, s.ossKey, copyKey))
+	info, err := s.local.Stat(ctx, copyKey)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "text/plain", info.ContentType)
+
+	// 覆盖副本不影响源文件
+	require.NoError(s.T(), s.local.Upload(ctx, copyKey, bytes.NewReader([]byte("new"))))
+	s.requireContent(s.ossKey, s.ossData)
+
+	require.NoError(s.T(), s.local.Move(ctx, copyKey, moveKey))
+	exists, err := s.local.Exists(ctx, copyKey)
+	require.NoError(s.T(), err)
+	require.False(s.T(), exists)
+	s.requireContent(moveKey, []byte("new"))
+
+	require.Error(s.T(), s.local.Copy(ctx, "not-exists", copyKey))
+}
+
+func (s *LocalTestSuite) requireContent(key string, expected []byte) {
+	readCloser, err := s.local.Download(context.Background(), key)
+	require.NoError(s.T(), err)
+	defer readCloser.Close()
+
+	actual, err := io.ReadAll(readCloser)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), expected, actual)
+}
+
+func (s *LocalTestSuite) TestLocal_DeleteMany() {
+	ctx := context.Background()
+	keys := []string{"tenant/a/1", "tenant/a/2", "tenant/b/1", "tenant-other"}
+	for _, key := range keys {
+		require.NoError(s.T(), s.local.Upload(ctx, key, bytes.NewReader(s.ossData)))
+	}
+
+	ret, err := s.local.DeleteMany(ctx, []string{"tenant/b/1", "not-exists"})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), []string{"tenant/b/1", "not-exists"}, ret.Deleted)
+	require.Empty(s.T(), ret.Errors)
+	require.NoDirExists(s.T(), filepath.Join(s.storePath, "tenant", "b"))
+
+	ret, err = s.local.DeletePrefix(ctx, "tenant/")
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), []string{"tenant/a/1", "tenant/a/2"}, ret.Deleted)
+	require.NoDirExists(s.T(), filepath.Join(s.storePath, "tenant"))
+
+	exists, err := s.local.Exists(ctx, "tenant-other")
+	require.NoError(s.T(), err)
+	require.True(s.T(), exists)
+
+	_, err = s.local.DeletePrefix(ctx, "")
+	require.ErrorIs(s.T(), err, oss.ErrEmptyPrefix)
+}
+
+func (s *LocalTestSuite) TestLocal_InvalidKey() {
+	ctx := context.Background()
+	for _, key := range []string{"../escape", "/etc/passwd", "a/../../escape", "a\x00b", ".oss/meta/x"} {
+		err := s.local.Upload(ctx, key, bytes.NewReader(s.ossData))
+		require.ErrorIs(s.T(), err, oss.ErrInvalidKey, key)
+		_, err = s.local.Download(ctx, key)
+		require.ErrorIs(s.T(), err, oss.ErrInvalidKey, key)
+		_, err = s.local.Exists(ctx, key)
+		require.ErrorIs(s.T(), err, oss.ErrInvalidKey, key)
+		require.ErrorIs(s.T(), s.local.Copy(ctx, s.ossKey, key), oss.ErrInvalidKey, key)
+	}
+	require.NoFileExists(s.T(), filepath.Join(filepath.Dir(s.storePath), "escape"))
+
+	_, err := s.local.List(ctx, "../", nil)
+	require.ErrorIs(s.T(), err, oss.ErrInvalidKey)
+
+	// 规范化后的 key 指向同一文件
+	s.TestLocal_Upload()
+	exists, err := s.local.Exists(ctx, "./"+s.ossKey)
+	require.NoError(s.T(), err)
+	require.True(s.T(), exists)
+}
+
 func (s *LocalTestSuite) TestLocal_Exists() {
 	_ = s.local.Delete(context.Background(), s.ossKey)
 	exists, err := s.local.Exists(context.Background(), s.ossKey)
@@ -70,3 +232,662 @@ func (s *LocalTestSuite) TestLocal_GenerateUrl() {
 	require.NoError(s.T(), err)
 	s.T().Logf("generate url: %s", url)
 }
+
+func (s *LocalTestSuite) TestLocal_Stat() {
+	s.TestLocal_Upload()
+
+	info, err := s.local.Stat(context.Background(), s.ossKey)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), s.ossKey, info.Key)
+	require.Equal(s.T(), int64(len(s.ossData)), info.Size)
+	require.Equal(s.T(), "application/octet-stream", info.ContentType)
+	require.False(s.T(), info.LastModified.IsZero())
+
+	_, err = s.local.Stat(context.Background(), "not-exists")
+	require.ErrorIs(s.T(), err, oss.ErrNotFound)
+}
+
+func (s *LocalTestSuite) TestLocal_Errors() {
+	ctx := context.Background()
+
+	_, err := s.local.Download(ctx, "not-exists")
+	require.ErrorIs(s.T(), err, oss.ErrNotFound)
+	require.ErrorIs(s.T(), err, fs.ErrNotExist)
+	var pathErr *fs.PathError
+	require.ErrorAs(s.T(), err, &pathErr)
+
+	// 上级路径是文件
+	_, err = s.local.Stat(ctx, s.ossKey+"/child")
+	require.ErrorIs(s.T(), err, oss.ErrNotFound)
+	exists, err := s.local.Exists(ctx, s.ossKey+"/child")
+	require.NoError(s.T(), err)
+	require.False(s.T(), exists)
+
+	require.NoError(s.T(), s.local.Delete(ctx, "not-exists"))
+	require.ErrorIs(s.T(), s.local.Copy(ctx, "not-exists", "dst"), oss.ErrNotFound)
+	require.ErrorIs(s.T(), s.local.Move(ctx, "not-exists", "dst"), oss.ErrNotFound)
+
+	ret, err := s.local.DeleteMany(ctx, []string{s.ossKey + "/child"})
+	require.NoError(s.T(), err)
+	require.Empty(s.T(), ret.Errors)
+}
+
+func (s *LocalTestSuite) TestLocal_UploadWithOptions() {
+	ctx := context.Background()
+	err := s.local.Upload(ctx, s.ossKey, bytes.NewReader(s.ossData),
+		oss.WithContentType("application/pdf"),
+		oss.WithContentDisposition("inline"),
+		oss.WithCacheControl("max-age=3600"),
+		oss.WithACL(oss.AclPublicRead),
+		oss.WithMetadata(map[string]string{"Tenant": "t1"}),
+	)
+	require.NoError(s.T(), err)
+
+	info, err := s.local.Stat(ctx, s.ossKey)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "application/pdf", info.ContentType)
+	require.Equal(s.T(), "inline", info.ContentDisposition)
+	require.Equal(s.T(), "max-age=3600", info.CacheControl)
+	require.Equal(s.T(), map[string]string{"tenant": "t1"}, info.Metadata)
+
+	// 再次上传时覆盖原有参数
+	s.TestLocal_Upload()
+	info, err = s.local.Stat(ctx, s.ossKey)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "application/octet-stream", info.ContentType)
+	require.Empty(s.T(), info.Metadata)
+}
+
+func (s *LocalTestSuite) TestLocal_UploadMultiPartWithOptions() {
+	ctx := context.Background()
+	key := "multiparts-test.pdf"
+	uploadId, err := s.local.CreateMultipartUpload(ctx, key, oss.WithContentType("application/pdf"))
+	require.NoError(s.T(), err)
+	defer s.local.AbortMultipartUpload(ctx, key, uploadId)
+
+	for i := 1; i <= 2; i++ {
+		_, err = s.local.UploadPart(ctx, key, uploadId, int64(i), bytes.NewReader(s.ossData))
+		require.NoError(s.T(), err)
+	}
+	_, err = s.local.CompleteMultipartUpload(ctx, key, uploadId, 2)
+	require.NoError(s.T(), err)
+
+	info, err := s.local.Stat(ctx, key)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "application/pdf", info.ContentType)
+	require.Equal(s.T(), int64(2*len(s.ossData)), info.Size)
+}
+
+func (s *LocalTestSuite) TestLocal_ETag() {
+	ctx := context.Background()
+	sum := md5.Sum(s.ossData)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	require.NoError(s.T(), s.local.Upload(ctx, "etag", bytes.NewReader(s.ossData)))
+	info, err := s.local.Stat(ctx, "etag")
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), etag, info.ETag)
+
+	// 分片上传的 ETag 为各分片 md5 拼接后的 md5 加分片数
+	key := "etag-multipart"
+	uploadId, err := s.local.CreateMultipartUpload(ctx, key)
+	require.NoError(s.T(), err)
+	for i := 1; i <= 2; i++ {
+		partETag, err := s.local.UploadPart(ctx, key, uploadId, int64(i), bytes.NewReader(s.ossData))
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), etag, partETag)
+	}
+	parts, err := s.local.ListParts(ctx, key, uploadId, 0)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), parts, 2)
+	for _, part := range parts {
+		require.Equal(s.T(), etag, part.ETag)
+	}
+	completed, err := s.local.CompleteMultipartUpload(ctx, key, uploadId, 2)
+	require.NoError(s.T(), err)
+	expected := md5.Sum(append(sum[:], sum[:]...))
+	require.Equal(s.T(), `"`+hex.EncodeToString(expected[:])+`-2"`, completed)
+	info, err = s.local.Stat(ctx, key)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), completed, info.ETag)
+
+	// 直接写入的文件在 Stat 时计算
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.storePath, "etag-raw"), s.ossData, 0644))
+	info, err = s.local.Stat(ctx, "etag-raw")
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), etag, info.ETag)
+}
+
+func (s *LocalTestSuite) TestLocal_Precondition() {
+	ctx := context.Background()
+	key := "precondition"
+
+	// 仅当文件不存在时上传
+	require.NoError(s.T(), s.local.Upload(ctx, key, bytes.NewReader([]byte("v1")), oss.WithIfNotExists()))
+	err := s.local.Upload(ctx, key, bytes.NewReader([]byte("v2")), oss.WithIfNotExists())
+	require.ErrorIs(s.T(), err, oss.ErrPreconditionFailed)
+	s.requireContent(key, []byte("v1"))
+
+	// 仅当 ETag 未变化时覆盖
+	info, err := s.local.Stat(ctx, key)
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), s.local.Upload(ctx, key, bytes.NewReader([]byte("v2")), oss.WithIfMatch(info.ETag)))
+	err = s.local.Upload(ctx, key, bytes.NewReader([]byte("v3")), oss.WithIfMatch(info.ETag))
+	require.ErrorIs(s.T(), err, oss.ErrPreconditionFailed)
+	s.requireContent(key, []byte("v2"))
+	err = s.local.Upload(ctx, "not-exists", bytes.NewReader([]byte("v1")), oss.WithIfMatch(info.ETag))
+	require.ErrorIs(s.T(), err, oss.ErrPreconditionFailed)
+
+	// 条件下载
+	_, err = s.local.Download(ctx, key, oss.WithDownloadIfMatch(info.ETag))
+	require.ErrorIs(s.T(), err, oss.ErrPreconditionFailed)
+	info, err = s.local.Stat(ctx, key)
+	require.NoError(s.T(), err)
+	_, err = s.local.Download(ctx, key, oss.WithDownloadIfNoneMatch(info.ETag))
+	require.ErrorIs(s.T(), err, oss.ErrPreconditionFailed)
+	readCloser, err := s.local.Download(ctx, key, oss.WithDownloadIfMatch(info.ETag))
+	require.NoError(s.T(), err)
+	readCloser.Close()
+}
+
+func (s *LocalTestSuite) TestLocal_UploadIfNotExistsConcurrently() {
+	ctx := context.Background()
+	key := "precondition-concurrent"
+
+	var (
+		wg      sync.WaitGroup
+		success int32
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.local.Upload(ctx, key, bytes.NewReader(s.ossData), oss.WithIfNotExists())
+			if err == nil {
+				atomic.AddInt32(&success, 1)
+				return
+			}
+			s.ErrorIs(err, oss.ErrPreconditionFailed)
+		}()
+	}
+	wg.Wait()
+	require.Equal(s.T(), int32(1), success)
+}
+
+func (s *LocalTestSuite) TestLocal_Tags() {
+	ctx := context.Background()
+	key := "tags"
+	tags := map[string]string{"tenant": "a", "retention": "30d"}
+	require.NoError(s.T(), s.local.Upload(ctx, key, bytes.NewReader(s.ossData), oss.WithTags(tags)))
+
+	actual, err := s.local.GetTags(ctx, key)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), tags, actual)
+
+	// 标签随文件复制
+	require.NoError(s.T(), s.local.Copy(ctx, key, "tags-copy"))
+	actual, err = s.local.GetTags(ctx, "tags-copy")
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), tags, actual)
+
+	require.NoError(s.T(), s.local.SetTags(ctx, key, map[string]string{"source": "b"}))
+	actual, err = s.local.GetTags(ctx, key)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), map[string]string{"source": "b"}, actual)
+
+	require.NoError(s.T(), s.local.SetTags(ctx, key, nil))
+	actual, err = s.local.GetTags(ctx, key)
+	require.NoError(s.T(), err)
+	require.Empty(s.T(), actual)
+
+	require.ErrorIs(s.T(), s.local.SetTags(ctx, key, map[string]string{"": "v"}), oss.ErrInvalidTags)
+	require.ErrorIs(s.T(), s.local.SetTags(ctx, "not-exists", tags), oss.ErrNotFound)
+	_, err = s.local.GetTags(ctx, "not-exists")
+	require.ErrorIs(s.T(), err, oss.ErrNotFound)
+}
+
+func (s *LocalTestSuite) TestLocal_UploadMultiPartNotFound() {
+	ctx := context.Background()
+	key := "multiparts-test"
+
+	for _, uploadId := range []string{"../../etc", uuid.New().String()} {
+		_, err := s.local.UploadPart(ctx, key, uploadId, 1, bytes.NewReader(s.ossData))
+		require.ErrorIs(s.T(), err, oss.ErrUploadNotFound)
+		_, err = s.local.ListParts(ctx, key, uploadId, 0)
+		require.ErrorIs(s.T(), err, oss.ErrUploadNotFound)
+	}
+
+	uploadId, err := s.local.CreateMultipartUpload(ctx, key)
+	require.NoError(s.T(), err)
+	require.DirExists(s.T(), filepath.Join(s.storePath, ".oss", "multipart", uploadId))
+
+	// key 不一致
+	_, err = s.local.UploadPart(ctx, "other", uploadId, 1, bytes.NewReader(s.ossData))
+	require.ErrorIs(s.T(), err, oss.ErrUploadNotFound)
+
+	_, err = s.local.UploadPart(ctx, key, uploadId, 1, bytes.NewReader(s.ossData))
+	require.NoError(s.T(), err)
+	_, err = s.local.CompleteMultipartUpload(ctx, key, uploadId, 1)
+	require.NoError(s.T(), err)
+
+	// 已完成
+	_, err = s.local.CompleteMultipartUpload(ctx, key, uploadId, 1)
+	require.ErrorIs(s.T(), err, oss.ErrUploadNotFound)
+	require.ErrorIs(s.T(), s.local.AbortMultipartUpload(ctx, key, uploadId), oss.ErrUploadNotFound)
+}
+
+func (s *LocalTestSuite) TestLocal_NestedKey() {
+	ctx := context.Background()
+	key := "tenant/2024/10/report.pdf"
+	require.NoError(s.T(), s.local.Upload(ctx, key, bytes.NewReader(s.ossData)))
+	require.NoError(s.T(), s.local.Copy(ctx, key, "copy/a/b"))
+	require.NoError(s.T(), s.local.Move(ctx, "copy/a/b", "move/a/b"))
+	s.requireContent("move/a/b", s.ossData)
+
+	uploadId, err := s.local.CreateMultipartUpload(ctx, "multipart/a/b")
+	require.NoError(s.T(), err)
+	_, err = s.local.UploadPart(ctx, "multipart/a/b", uploadId, 1, bytes.NewReader(s.ossData))
+	require.NoError(s.T(), err)
+	_, err = s.local.CompleteMultipartUpload(ctx, "multipart/a/b", uploadId, 1)
+	require.NoError(s.T(), err)
+	s.requireContent("multipart/a/b", s.ossData)
+
+	// 默认不清理空目录
+	require.NoError(s.T(), s.local.Delete(ctx, key))
+	require.DirExists(s.T(), filepath.Join(s.storePath, "tenant", "2024", "10"))
+}
+
+func TestLocal_ParentIsObject(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocal(t.TempDir(), "")
+	require.NoError(t, err)
+	require.NoError(t, store.Upload(ctx, "a", bytes.NewReader([]byte("1"))))
+	require.NoError(t, store.Upload(ctx, "src", bytes.NewReader([]byte("2"))))
+
+	// 上级路径是已存在的文件，与 key 是已存在的目录时相同返回 ErrAlreadyExists
+	for _, err := range []error{
+		store.Upload(ctx, "a/b", bytes.NewReader([]byte("3"))),
+		store.Copy(ctx, "src", "a/b"),
+		store.Move(ctx, "src", "a/b"),
+	} {
+		require.ErrorIs(t, err, oss.ErrAlreadyExists)
+		require.NotErrorIs(t, err, oss.ErrNotFound)
+	}
+	uploadId, err := store.CreateMultipartUpload(ctx, "a/b")
+	require.NoError(t, err)
+	_, err = store.UploadPart(ctx, "a/b", uploadId, 1, bytes.NewReader([]byte("3")))
+	require.NoError(t, err)
+	_, err = store.CompleteMultipartUpload(ctx, "a/b", uploadId, 1)
+	require.ErrorIs(t, err, oss.ErrAlreadyExists)
+
+	// 读取时仍视为不存在
+	_, err = store.Stat(ctx, "a/b")
+	require.ErrorIs(t, err, oss.ErrNotFound)
+}
+
+func TestLocal_MoveDirectoryKey(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocal(t.TempDir(), "")
+	require.NoError(t, err)
+	require.NoError(t, store.Upload(ctx, "tenant/a.txt", bytes.NewReader([]byte("1")), oss.WithContentType("text/csv")))
+
+	// 上级目录不是文件，不能移动
+	require.ErrorIs(t, store.Move(ctx, "tenant", "other"), oss.ErrNotFound)
+	info, err := store.Stat(ctx, "tenant/a.txt")
+	require.NoError(t, err)
+	require.Equal(t, "text/csv", info.ContentType)
+	_, err = store.Stat(ctx, "other/a.txt")
+	require.ErrorIs(t, err, oss.ErrNotFound)
+}
+
+func TestLocal_PruneEmptyDirs(t *testing.T) {
+	ctx := context.Background()
+	storePath := t.TempDir()
+	store, err := NewLocal(storePath, "", WithPruneEmptyDirs())
+	require.NoError(t, err)
+
+	for _, key := range []string{"tenant/2024/10/a", "tenant/2024/11/a"} {
+		require.NoError(t, store.Upload(ctx, key, bytes.NewReader([]byte("1")), oss.WithContentType("text/plain")))
+	}
+	require.NoError(t, store.Delete(ctx, "tenant/2024/10/a"))
+	require.NoDirExists(t, filepath.Join(storePath, "tenant", "2024", "10"))
+	require.DirExists(t, filepath.Join(storePath, "tenant", "2024", "11"))
+
+	require.NoError(t, store.Move(ctx, "tenant/2024/11/a", "other"))
+	require.NoDirExists(t, filepath.Join(storePath, "tenant"))
+	require.DirExists(t, storePath)
+}
+
+func TestLocal_Versioning(t *testing.T) {
+	ctx := context.Background()
+	storePath := t.TempDir()
+	store, err := NewLocal(storePath, "", WithVersioning())
+	require.NoError(t, err)
+	key := "versions/a.txt"
+
+	download := func(versionId string) string {
+		readCloser, err := store.DownloadVersion(ctx, key, versionId)
+		require.NoError(t, err)
+		defer readCloser.Close()
+		data, err := io.ReadAll(readCloser)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	for _, v := range []string{"v1", "v2", "v3"} {
+		require.NoError(t, store.Upload(ctx, key, bytes.NewReader([]byte(v))))
+	}
+	versions, err := store.ListVersions(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	require.True(t, versions[0].IsLatest)
+	require.False(t, versions[1].IsLatest)
+	require.Equal(t, "v3", download(versions[0].VersionId))
+	require.Equal(t, "v2", download(versions[1].VersionId))
+	require.Equal(t, "v1", download(versions[2].VersionId))
+	info, err := store.Stat(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, versions[0].VersionId, info.VersionId)
+
+	// 恢复旧版本
+	require.NoError(t, store.RestoreVersion(ctx, key, versions[2].VersionId))
+	readCloser, err := store.Download(ctx, key)
+	require.NoError(t, err)
+	data, err := io.ReadAll(readCloser)
+	readCloser.Close()
+	require.NoError(t, err)
+	require.Equal(t, "v1", string(data))
+
+	// 删除后只剩删除标记，历史版本仍可下载
+	require.NoError(t, store.Delete(ctx, key))
+	exists, err := store.Exists(ctx, key)
+	require.NoError(t, err)
+	require.False(t, exists)
+	versions, err = store.ListVersions(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, versions, 5)
+	require.True(t, versions[0].IsDeleteMarker)
+	require.True(t, versions[0].IsLatest)
+	require.Equal(t, "v1", download(versions[1].VersionId))
+	_, err = store.DownloadVersion(ctx, key, versions[0].VersionId)
+	require.ErrorIs(t, err, oss.ErrNotFound)
+
+	// 删除删除标记后上一个版本成为当前版本
+	require.NoError(t, store.DeleteVersion(ctx, key, versions[0].VersionId))
+	info, err = store.Stat(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, versions[1].VersionId, info.VersionId)
+
+	// 永久删除当前版本
+	require.NoError(t, store.DeleteVersion(ctx, key, versions[1].VersionId))
+	versions, err = store.ListVersions(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	require.Equal(t, "v3", download(versions[0].VersionId))
+
+	require.ErrorIs(t, store.DeleteVersion(ctx, key, "../../a"), oss.ErrNotFound)
+	require.ErrorIs(t, store.DeleteVersion(ctx, key, uuid.New().String()), oss.ErrNotFound)
+
+	// 历史版本不出现在列举结果中
+	ret, err := store.List(ctx, "", nil)
+	require.NoError(t, err)
+	require.Len(t, ret.Objects, 1)
+}
+
+func (s *LocalTestSuite) TestLocal_VersioningDisabled() {
+	ctx := context.Background()
+	key := "no-versions"
+	require.NoError(s.T(), s.local.Upload(ctx, key, bytes.NewReader([]byte("v1"))))
+	require.NoError(s.T(), s.local.Upload(ctx, key, bytes.NewReader([]byte("v2"))))
+
+	versions, err := s.local.ListVersions(ctx, key)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), versions, 1)
+	require.Equal(s.T(), oss.NullVersionId, versions[0].VersionId)
+
+	require.NoError(s.T(), s.local.DeleteVersion(ctx, key, oss.NullVersionId))
+	exists, err := s.local.Exists(ctx, key)
+	require.NoError(s.T(), err)
+	require.False(s.T(), exists)
+}
+
+func TestLocal_Expires(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocal(t.TempDir(), "")
+	require.NoError(t, err)
+	r := store.(*local)
+
+	require.NoError(t, store.Upload(ctx, "tmp/export.csv", bytes.NewReader([]byte("1")), oss.WithTTL(time.Hour)))
+	require.NoError(t, store.Upload(ctx, "tmp/thumbnail.png", bytes.NewReader([]byte("1")), oss.WithTTL(3*time.Hour)))
+	require.NoError(t, store.Upload(ctx, "keep", bytes.NewReader([]byte("1"))))
+
+	ret, err := r.deleteExpired(ctx)
+	require.NoError(t, err)
+	require.Empty(t, ret.Deleted)
+
+	// 过期后在被删除前即不可见
+	r.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	exists, err := store.Exists(ctx, "tmp/export.csv")
+	require.NoError(t, err)
+	require.False(t, exists)
+	list, err := store.List(ctx, "tmp/", nil)
+	require.NoError(t, err)
+	require.Len(t, list.Objects, 1)
+
+	ret, err = r.deleteExpired(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"tmp/export.csv"}, ret.Deleted)
+	require.Empty(t, ret.Errors)
+
+	// 重新上传后不再过期
+	require.NoError(t, store.Upload(ctx, "tmp/thumbnail.png", bytes.NewReader([]byte("2"))))
+	r.now = func() time.Time { return time.Now().Add(4 * time.Hour) }
+	ret, err = r.deleteExpired(ctx)
+	require.NoError(t, err)
+	require.Empty(t, ret.Deleted)
+
+	list, err = store.List(ctx, "", nil)
+	require.NoError(t, err)
+	require.Len(t, list.Objects, 2)
+}
+
+func TestLocal_Janitor(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocal(t.TempDir(), "", WithJanitor(10*time.Millisecond))
+	require.NoError(t, err)
+	defer store.(io.Closer).Close()
+
+	require.NoError(t, store.Upload(ctx, "expired", bytes.NewReader([]byte("1")), oss.WithExpires(time.Now())))
+	require.Eventually(t, func() bool {
+		exists, err := store.Exists(ctx, "expired")
+		return err == nil && !exists
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, store.(io.Closer).Close())
+	require.NoError(t, store.(io.Closer).Close())
+}
+
+func TestLocal_StagingDir(t *testing.T) {
+	stagingDir := filepath.Join(t.TempDir(), "staging")
+	store, err := NewLocal(t.TempDir(), "", WithStagingDir(stagingDir))
+	require.NoError(t, err)
+
+	uploadId, err := store.CreateMultipartUpload(context.Background(), "key")
+	require.NoError(t, err)
+	require.DirExists(t, filepath.Join(stagingDir, uploadId))
+}
+
+func (s *LocalTestSuite) TestLocal_List() {
+	ctx := context.Background()
+	keys := []string{"list/a", "list/b/c", "list/b/d", "list/e", "list-other"}
+	for _, key := range keys {
+		require.NoError(s.T(), s.local.Upload(ctx, key, bytes.NewReader(s.ossData)))
+	}
+
+	ret, err := s.local.List(ctx, "list/", nil)
+	require.NoError(s.T(), err)
+	require.False(s.T(), ret.IsTruncated)
+	require.Len(s.T(), ret.Objects, 4)
+	require.Equal(s.T(), "list/a", ret.Objects[0].Key)
+	require.Equal(s.T(), int64(len(s.ossData)), ret.Objects[0].Size)
+
+	// 按目录归组并分页
+	var (
+		objects  []string
+		prefixes []string
+		opts     = &oss.ListOptions{Delimiter: "/", MaxKeys: 1}
+	)
+	for {
+		ret, err = s.local.List(ctx, "list/", opts)
+		require.NoError(s.T(), err)
+		for _, obj := range ret.Objects {
+			objects = append(objects, obj.Key)
+		}
+		prefixes = append(prefixes, ret.CommonPrefixes...)
+		if !ret.IsTruncated {
+			break
+		}
+		opts.ContinuationToken = ret.NextContinuationToken
+	}
+	require.Equal(s.T(), []string{"list/a", "list/e"}, objects)
+	require.Equal(s.T(), []string{"list/b/"}, prefixes)
+}
+
+func TestLocal_ListMultipartUploads(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocal(t.TempDir(), "")
+	require.NoError(t, err)
+
+	uploads, err := store.ListMultipartUploads(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, uploads)
+
+	a, err := store.CreateMultipartUpload(ctx, "dir/a")
+	require.NoError(t, err)
+	b, err := store.CreateMultipartUpload(ctx, "dir/b")
+	require.NoError(t, err)
+	other, err := store.CreateMultipartUpload(ctx, "other")
+	require.NoError(t, err)
+
+	uploads, err = store.ListMultipartUploads(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, uploads, 3)
+	require.Equal(t, other, uploads[2].UploadId)
+	require.False(t, uploads[2].Initiated.IsZero())
+
+	uploads, err = store.ListMultipartUploads(ctx, "dir/")
+	require.NoError(t, err)
+	require.Len(t, uploads, 2)
+	require.Equal(t, "dir/a", uploads[0].Key)
+	require.Equal(t, a, uploads[0].UploadId)
+	require.Equal(t, b, uploads[1].UploadId)
+
+	// 已终止的不再列出
+	require.NoError(t, store.AbortMultipartUpload(ctx, "dir/a", a))
+	uploads, err = store.ListMultipartUploads(ctx, "dir/")
+	require.NoError(t, err)
+	require.Len(t, uploads, 1)
+
+	_, err = store.ListMultipartUploads(ctx, "../")
+	require.ErrorIs(t, err, oss.ErrInvalidKey)
+}
+
+func TestLocal_Conformance(t *testing.T) {
+	// 通过 handler 提供链接访问，以便检查过期的链接被拒绝
+	store, _ := newTestServer(t)
+	osstest.Run(t, store)
+}
+
+func TestLocal_VersioningConformance(t *testing.T) {
+	store, err := NewLocal(t.TempDir(), "", WithVersioning())
+	require.NoError(t, err)
+	osstest.RunVersioning(t, store)
+}
+
+func TestLocal_InternalPaths(t *testing.T) {
+	ctx := context.Background()
+	storePath := t.TempDir()
+	store, err := NewLocal(storePath, "", WithStagingDir(filepath.Join(storePath, "uploads")))
+	require.NoError(t, err)
+
+	require.NoError(t, store.Upload(ctx, "a.txt", bytes.NewReader([]byte("1"))))
+	uploadId, err := store.CreateMultipartUpload(ctx, "b.txt")
+	require.NoError(t, err)
+	_, err = store.UploadPart(ctx, "b.txt", uploadId, 1, bytes.NewReader([]byte("1")))
+	require.NoError(t, err)
+
+	// 分片目录及 sidecar 不会被列举或删除
+	result, err := store.List(ctx, "", nil)
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 1)
+	require.Equal(t, "a.txt", result.Objects[0].Key)
+	deleted, err := store.DeletePrefix(ctx, "uploads/")
+	require.NoError(t, err)
+	require.Empty(t, deleted.Deleted)
+	parts, err := store.ListParts(ctx, "b.txt", uploadId, 0)
+	require.NoError(t, err)
+	require.Len(t, parts, 1)
+
+	_, err = store.List(ctx, ".oss/meta/", nil)
+	require.ErrorIs(t, err, oss.ErrInvalidKey)
+	_, err = store.DeletePrefix(ctx, ".oss/")
+	require.ErrorIs(t, err, oss.ErrInvalidKey)
+	require.ErrorIs(t, store.Upload(ctx, "uploads/"+uploadId+"/upload.json", bytes.NewReader(nil)), oss.ErrInvalidKey)
+}
+
+func TestLocal_DeleteDirectoryKey(t *testing.T) {
+	ctx := context.Background()
+	storePath := t.TempDir()
+	store, err := NewLocal(storePath, "")
+	require.NoError(t, err)
+	require.NoError(t, store.Upload(ctx, "tenant/a.txt", bytes.NewReader([]byte("1"))))
+	require.NoError(t, os.Mkdir(filepath.Join(storePath, "empty"), os.ModePerm))
+
+	// 上级目录不是文件，视为不存在
+	for _, key := range []string{"tenant", "empty"} {
+		require.NoError(t, store.Delete(ctx, key))
+	}
+	require.DirExists(t, filepath.Join(storePath, "empty"))
+
+	ret, err := store.DeleteMany(ctx, []string{"tenant"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"tenant"}, ret.Deleted)
+	require.Empty(t, ret.Errors)
+	exists, err := store.Exists(ctx, "tenant/a.txt")
+	require.NoError(t, err)
+	require.True(t, exists)
+}
+
+func TestLocal_DirectoryKeyMetadata(t *testing.T) {
+	ctx := context.Background()
+	storePath := t.TempDir()
+	store, err := NewLocal(storePath, "")
+	require.NoError(t, err)
+	require.NoError(t, store.Upload(ctx, "tenant/a.txt", bytes.NewReader([]byte("1"))))
+
+	// 上级目录不是文件，不能读写标签或公开
+	_, err = store.GetTags(ctx, "tenant")
+	require.ErrorIs(t, err, oss.ErrNotFound)
+	require.ErrorIs(t, store.SetTags(ctx, "tenant", map[string]string{"k": "v"}), oss.ErrNotFound)
+	_, err = store.GeneratePermanentUrl(ctx, "tenant")
+	require.ErrorIs(t, err, oss.ErrNotFound)
+	require.NoFileExists(t, store.(*local).getMetaPath("tenant"))
+}
+
+func TestLocal_MetaPathCollision(t *testing.T) {
+	ctx := context.Background()
+	for _, keys := range [][]string{{"a", "a.json/b"}, {"x.json/b", "x"}} {
+		store, err := NewLocal(t.TempDir(), "")
+		require.NoError(t, err)
+		for _, key := range keys {
+			require.NoError(t, store.Upload(ctx, key, bytes.NewReader([]byte(key)), oss.WithContentType("text/plain")))
+		}
+		for _, key := range keys {
+			info, err := store.Stat(ctx, key)
+			require.NoError(t, err)
+			sum := md5.Sum([]byte(key))
+			require.Equal(t, "text/plain", info.ContentType)
+			require.Equal(t, oss.MD5ETag(sum[:]), info.ETag)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package local
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/blues120/ias-kit/oss"
+)
+
+// internalDir storePath 下存放元数据等内部文件的隐藏目录，列举时跳过
+const internalDir = ".oss"
+
+// objectMeta 文件系统无法保存的对象信息，以 json 形式存放在 sidecar 文件中
+type objectMeta struct {
+	ETag               string            `json:"etag,omitempty"`
+	ContentType        string            `json:"contentType,omitempty"`
+	ContentDisposition string            `json:"contentDisposition,omitempty"`
+	CacheControl       string            `json:"cacheControl,omitempty"`
+	ContentEncoding    string            `json:"contentEncoding,omitempty"`
+	ACL                string            `json:"acl,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+
+	// Expires 过期时间，过期后立即不可见，由 janitor 删除过期的文件
+	Expires *time.Time `json:"expires,omitempty"`
+
+	// VersionId 开启多版本后当前版本的 id
+	VersionId string `json:"versionId,omitempty"`
+}
+
+// sidecar sidecar 文件内容，文件名为 key 的哈希，须保存 key 以便 janitor 遍历
+type sidecar struct {
+	Key string `json:"key"`
+	*objectMeta
+}
+
+// newObjectMeta 根据上传参数生成元数据
+func newObjectMeta(o *oss.UploadOptions) *objectMeta {
+	meta := &objectMeta{
+		ContentType:        o.ContentType,
+		ContentDisposition: o.ContentDisposition,
+		CacheControl:       o.CacheControl,
+		ContentEncoding:    o.ContentEncoding,
+		ACL:                o.ACL,
+		Metadata:           o.Metadata,
+		Tags:               o.Tags,
+	}
+	if !o.Expires.IsZero() {
+		expires := o.Expires
+		meta.Expires = &expires
+	}
+	return meta
+}
+
+// expired 判断对象在 now 时是否已过期
+func (m *objectMeta) expired(now time.Time) bool {
+	return m.Expires != nil && !now.Before(*m.Expires)
+}
+
+// getMetaDir 获取 sidecar 目录
+func (r *local) getMetaDir() string {
+	return filepath.Join(r.storePath, internalDir, "meta")
+}
+
+// getMetaPath 获取 sidecar 文件路径，与 getVersionDir 相同使用 key 的哈希
+// 避免 "a" 的 sidecar "a.json" 与 "a.json/b" 的上级目录冲突
+func (r *local) getMetaPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(r.getMetaDir(), hex.EncodeToString(sum[:])+".json")
+}
+
+// readMeta 读取 sidecar，不存在时返回空的元数据
+func (r *local) readMeta(key string) (*objectMeta, error) {
+	s, err := readSidecar(r.getMetaPath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &objectMeta{}, nil
+		}
+		return nil, err
+	}
+	return s.objectMeta, nil
+}
+
+// readSidecar 读取 p 处的 sidecar 文件
+func readSidecar(p string) (*sidecar, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	s := &sidecar{objectMeta: &objectMeta{}}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// writeMeta 写入 sidecar，元数据为空时删除 sidecar
+func (r *local) writeMeta(key string, meta *objectMeta) error {
+	tmp, err := r.writeMetaTemp(key, meta)
+	if err != nil {
+		return err
+	}
+	return r.commitMeta(key, tmp)
+}
+
+// writeMetaTemp 将 sidecar 写入临时文件并返回其路径，元数据为空时返回空字符串
+// 须在之后调用 commitMeta 或删除临时文件
+func (r *local) writeMetaTemp(key string, meta *objectMeta) (string, error) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return "", err
+	}
+	if string(data) == "{}" {
+		return "", nil
+	}
+	if data, err = json.Marshal(&sidecar{Key: key, objectMeta: meta}); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(r.getMetaDir(), os.ModePerm); err != nil {
+		return "", err
+	}
+	return writeTempFile(r.getMetaDir(), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// commitMeta 将 writeMetaTemp 写入的临时文件重命名为 sidecar，tmp 为空时删除 sidecar
+func (r *local) commitMeta(key, tmp string) error {
+	if tmp == "" {
+		return r.removeMeta(key)
+	}
+	return commitFile(tmp, r.getMetaPath(key))
+}
+
+// removeMeta 删除 sidecar
+func (r *local) removeMeta(key string) error {
+	err := os.Remove(r.getMetaPath(key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
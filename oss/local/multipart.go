@@ -0,0 +1,160 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blues120/ias-kit/oss"
+	"github.com/google/uuid"
+)
+
+// maxPartNumber 与 s3 相同，分片编号最大为 10000
+const maxPartNumber = 10000
+
+// multipartUpload 分片上传信息，保存在分片目录的 upload.json 中
+type multipartUpload struct {
+	Key       string    `json:"key"`
+	Initiated time.Time `json:"initiated"`
+
+	// Meta 创建分片上传时的上传参数，完成时写入 sidecar
+	Meta *objectMeta `json:"meta"`
+}
+
+// getUploadDir 获取分片目录，uploadId 须先经过 validUploadId 校验
+func (r *local) getUploadDir(uploadId string) string {
+	return filepath.Join(r.stagingDir, uploadId)
+}
+
+func (r *local) getUploadPartPath(uploadId string, partNumber int64) string {
+	return filepath.Join(r.getUploadDir(uploadId), "part_"+strconv.FormatInt(partNumber, 10)+".tmp")
+}
+
+func (r *local) getUploadPartETagPath(uploadId string, partNumber int64) string {
+	return filepath.Join(r.getUploadDir(uploadId), "part_"+strconv.FormatInt(partNumber, 10)+".etag")
+}
+
+// writePartETag 保存分片的 ETag，供 ListParts 返回
+func (r *local) writePartETag(uploadId string, partNumber int64, etag string) error {
+	return writeFileAtomic(r.getUploadPartETagPath(uploadId, partNumber), func(w io.Writer) error {
+		_, err := io.WriteString(w, etag)
+		return err
+	})
+}
+
+// readPartETag 读取分片的 ETag，没有保存时根据分片内容计算
+func (r *local) readPartETag(uploadId string, partNumber int64) (string, error) {
+	data, err := os.ReadFile(r.getUploadPartETagPath(uploadId, partNumber))
+	if err == nil {
+		return string(data), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	return fileETag(r.getUploadPartPath(uploadId, partNumber))
+}
+
+func (r *local) getUploadInfoPath(uploadId string) string {
+	return filepath.Join(r.getUploadDir(uploadId), "upload.json")
+}
+
+// readUpload 读取分片上传信息
+// uploadId 非法、分片上传不存在（已完成或已终止）或 key 不一致时返回 oss.ErrUploadNotFound
+func (r *local) readUpload(key, uploadId string) (*multipartUpload, error) {
+	if !validUploadId(uploadId) {
+		return nil, oss.ErrUploadNotFound
+	}
+
+	data, err := os.ReadFile(r.getUploadInfoPath(uploadId))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, oss.ErrUploadNotFound
+		}
+		return nil, err
+	}
+	upload := &multipartUpload{}
+	if err := json.Unmarshal(data, upload); err != nil {
+		return nil, err
+	}
+	if upload.Key != key {
+		return nil, oss.ErrUploadNotFound
+	}
+	if upload.Meta == nil {
+		upload.Meta = &objectMeta{}
+	}
+	return upload, nil
+}
+
+func (r *local) writeUpload(uploadId string, upload *multipartUpload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(r.getUploadInfoPath(uploadId), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// validUploadId uploadId 须为 CreateMultipartUpload 生成的标准格式 uuid，防止通过 uploadId 访问分片目录以外的路径
+func validUploadId(uploadId string) bool {
+	id, err := uuid.Parse(uploadId)
+	return err == nil && id.String() == uploadId
+}
+
+// ListMultipartUploads 遍历分片目录，读取各分片上传的 upload.json
+func (r *local) ListMultipartUploads(ctx context.Context, prefix string) (_ []*oss.MultipartUpload, err error) {
+	defer wrapError(&err)
+	if err := oss.ValidatePrefix(prefix); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(r.stagingDir)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*oss.MultipartUpload, 0)
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !entry.IsDir() || !validUploadId(entry.Name()) {
+			continue
+		}
+
+		data, err := os.ReadFile(r.getUploadInfoPath(entry.Name()))
+		if err != nil {
+			// 列举期间已完成或已终止
+			if isNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		upload := &multipartUpload{}
+		if err := json.Unmarshal(data, upload); err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(upload.Key, prefix) {
+			continue
+		}
+		ret = append(ret, &oss.MultipartUpload{
+			Key:       upload.Key,
+			UploadId:  entry.Name(),
+			Initiated: upload.Initiated,
+		})
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Key != ret[j].Key {
+			return ret[i].Key < ret[j].Key
+		}
+		return ret[i].Initiated.Before(ret[j].Initiated)
+	})
+	return ret, nil
+}
@@ -0,0 +1,214 @@
+package local
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blues120/ias-kit/oss"
+)
+
+// 签名链接的查询参数
+const (
+	queryExpires       = "expires"
+	querySignature     = "signature"
+	querySignedHeaders = "signedHeaders"
+	queryUploadId      = "uploadId"
+	queryPartNumber    = "partNumber"
+
+	// queryObjectExpires 上传的文件的过期时间，由 oss.WithExpires 或 oss.WithTTL 设置
+	// 参与签名但不需要客户端携带请求头，WithTTL 的有效期自生成链接起计算
+	queryObjectExpires = "objectExpires"
+)
+
+var (
+	errSignatureMismatch = errors.New("signature does not match")
+	errUrlExpired        = errors.New("url has expired")
+	errUnsignedHeader    = errors.New("header is not signed")
+)
+
+// signedUrl 生成 path 前缀下带签名及过期时间的链接
+// 签名内容为请求方法、key、过期时间、分片信息及需要校验的请求头，见 stringToSign
+func (r *local) signedUrl(method, key string, expire time.Duration, query url.Values, header http.Header) string {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set(queryExpires, strconv.FormatInt(r.now().Add(expire).Unix(), 10))
+
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		query.Set(querySignedHeaders, strings.Join(names, ";"))
+	}
+
+	query.Set(querySignature, r.sign(method, key, query, header))
+	return r.getUrl(key) + "?" + query.Encode()
+}
+
+// verifyUrl 校验请求的签名及过期时间
+func (r *local) verifyUrl(req *http.Request, method, key string) error {
+	query := req.URL.Query()
+	signature, err := hex.DecodeString(query.Get(querySignature))
+	if err != nil || len(signature) == 0 {
+		return errSignatureMismatch
+	}
+
+	expected, _ := hex.DecodeString(r.sign(method, key, query, signedHeader(req)))
+	if !hmac.Equal(signature, expected) {
+		return errSignatureMismatch
+	}
+
+	expires, err := strconv.ParseInt(query.Get(queryExpires), 10, 64)
+	if err != nil {
+		return errSignatureMismatch
+	}
+	if r.now().Unix() > expires {
+		return errUrlExpired
+	}
+	return nil
+}
+
+// signedHeader 请求中参与签名的请求头，即 signedHeaders 中列出的请求头
+func signedHeader(req *http.Request) http.Header {
+	header := http.Header{}
+	if signed := req.URL.Query().Get(querySignedHeaders); signed != "" {
+		for _, name := range strings.Split(signed, ";") {
+			header.Set(name, req.Header.Get(name))
+		}
+	}
+	return header
+}
+
+// checkUnsignedHeader 与 s3 相同，上传时影响上传参数的请求头须参与签名
+// 否则持有上传链接的人可以通过额外的请求头修改 ACL 等参数
+func checkUnsignedHeader(req *http.Request) error {
+	signed := signedHeader(req)
+	for name := range req.Header {
+		k := strings.ToLower(name)
+		if k == "content-length" {
+			continue
+		}
+		if _, ok := signed[http.CanonicalHeaderKey(k)]; ok {
+			continue
+		}
+		if strings.HasPrefix(k, "x-amz-") || strings.HasPrefix(k, "content-") || k == "cache-control" {
+			return fmt.Errorf("%w: %s", errUnsignedHeader, name)
+		}
+	}
+	return nil
+}
+
+func (r *local) sign(method, key string, query url.Values, header http.Header) string {
+	mac := hmac.New(sha256.New, r.signKey)
+	mac.Write([]byte(stringToSign(method, key, query, header)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// stringToSign 生成待签名字符串，每行一项，请求头按名称排序
+func stringToSign(method, key string, query url.Values, header http.Header) string {
+	lines := []string{
+		method,
+		key,
+		query.Get(queryExpires),
+		query.Get(queryUploadId),
+		query.Get(queryPartNumber),
+		query.Get(querySignedHeaders),
+		query.Get(queryObjectExpires),
+	}
+
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, name+":"+strings.TrimSpace(header.Get(name)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// getUrl 获取 path 前缀下 key 对应的链接，不含查询参数
+func (r *local) getUrl(key string) string {
+	segments := strings.Split(key, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	return strings.TrimSuffix(r.path, "/") + "/" + strings.Join(segments, "/")
+}
+
+// uploadHeader 上传参数对应的请求头，客户端上传时须携带
+func uploadHeader(o *oss.UploadOptions) http.Header {
+	header := http.Header{}
+	setHeader := func(name, value string) {
+		if value != "" {
+			header.Set(name, value)
+		}
+	}
+	setHeader("Content-Type", o.ContentType)
+	setHeader("Content-Disposition", o.ContentDisposition)
+	setHeader("Cache-Control", o.CacheControl)
+	setHeader("Content-Encoding", o.ContentEncoding)
+	setHeader("X-Amz-Acl", o.ACL)
+	if len(o.Tags) > 0 {
+		setHeader("X-Amz-Tagging", oss.EncodeTags(o.Tags))
+	}
+	for k, v := range o.Metadata {
+		setHeader("X-Amz-Meta-"+k, v)
+	}
+	return header
+}
+
+// objectExpiresQuery 将文件的过期时间写入查询参数，未设置时不写入
+func objectExpiresQuery(o *oss.UploadOptions) url.Values {
+	query := url.Values{}
+	if !o.Expires.IsZero() {
+		query.Set(queryObjectExpires, o.Expires.UTC().Format(time.RFC3339Nano))
+	}
+	return query
+}
+
+// objectExpiresOption 从查询参数还原文件的过期时间，query 须已通过签名校验
+func objectExpiresOption(query url.Values) (oss.UploadOption, error) {
+	var expires time.Time
+	if v := query.Get(queryObjectExpires); v != "" {
+		var err error
+		if expires, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", queryObjectExpires, err)
+		}
+	}
+	return oss.WithExpires(expires), nil
+}
+
+// uploadOptionsFromHeader 从请求头还原上传参数，header 须仅包含参与签名的请求头
+func uploadOptionsFromHeader(header http.Header) ([]oss.UploadOption, error) {
+	metadata := make(map[string]string)
+	for name := range header {
+		if k := strings.ToLower(name); strings.HasPrefix(k, "x-amz-meta-") {
+			metadata[strings.TrimPrefix(k, "x-amz-meta-")] = header.Get(name)
+		}
+	}
+	tags, err := oss.ParseTags(header.Get("X-Amz-Tagging"))
+	if err != nil {
+		return nil, err
+	}
+	return []oss.UploadOption{
+		oss.WithContentType(header.Get("Content-Type")),
+		oss.WithContentDisposition(header.Get("Content-Disposition")),
+		oss.WithCacheControl(header.Get("Cache-Control")),
+		oss.WithContentEncoding(header.Get("Content-Encoding")),
+		oss.WithACL(header.Get("X-Amz-Acl")),
+		oss.WithMetadata(metadata),
+		oss.WithTags(tags),
+	}, nil
+}
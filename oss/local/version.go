@@ -0,0 +1,409 @@
+package local
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blues120/ias-kit/oss"
+	"github.com/google/uuid"
+)
+
+// 开启多版本后，被覆盖或删除的版本保存在 storePath/.oss/versions/<key 的 sha256>/ 下
+//   - <versionId> 版本的文件内容，与当前文件共享硬链接时不额外占用空间
+//   - <versionId>.json 版本信息，见 versionRecord
+// 当前版本仍保存在 key 对应的路径，版本 id 记录在 sidecar 中
+
+// versionRecord 历史版本信息
+type versionRecord struct {
+	Key          string    `json:"key"`
+	VersionId    string    `json:"versionId"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+
+	// Archived 成为历史版本的时间，用于排序
+	Archived time.Time `json:"archived"`
+
+	DeleteMarker bool        `json:"deleteMarker,omitempty"`
+	Meta         *objectMeta `json:"meta,omitempty"`
+}
+
+// getVersionDir 获取 key 的历史版本目录，使用 key 的哈希避免与其他 key 的目录冲突
+func (r *local) getVersionDir(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(r.storePath, internalDir, "versions", hex.EncodeToString(sum[:]))
+}
+
+// getVersionPath 获取历史版本的文件路径，versionId 须先经过 validVersionId 校验
+func (r *local) getVersionPath(key, versionId string) string {
+	return filepath.Join(r.getVersionDir(key), versionId)
+}
+
+func (r *local) getVersionRecordPath(key, versionId string) string {
+	return filepath.Join(r.getVersionDir(key), versionId+".json")
+}
+
+// validVersionId 版本 id 须为 uuid 或 "null"，防止通过版本 id 访问版本目录以外的路径
+func validVersionId(versionId string) bool {
+	return versionId == oss.NullVersionId || validUploadId(versionId)
+}
+
+// newVersionId 生成版本 id
+func newVersionId() string {
+	return uuid.New().String()
+}
+
+// currentVersionId 当前版本的 id，未开启多版本时写入的文件为 "null"
+func currentVersionId(meta *objectMeta) string {
+	if meta.VersionId == "" {
+		return oss.NullVersionId
+	}
+	return meta.VersionId
+}
+
+// archiveCurrent 将当前版本复制到历史版本目录，当前文件不存在时不做处理，调用方须持有写锁
+func (r *local) archiveCurrent(key string) error {
+	p := r.getSavePath(key)
+	info, err := statObject(p)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	meta, err := r.readMeta(key)
+	if err != nil {
+		return err
+	}
+	if meta.ETag == "" {
+		if meta.ETag, err = fileETag(p); err != nil {
+			return err
+		}
+	}
+
+	versionId := currentVersionId(meta)
+	if err := os.MkdirAll(r.getVersionDir(key), os.ModePerm); err != nil {
+		return err
+	}
+	if err := linkOrCopyFile(p, r.getVersionPath(key, versionId)); err != nil {
+		return err
+	}
+	return r.writeVersionRecord(&versionRecord{
+		Key:          key,
+		VersionId:    versionId,
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+		Archived:     r.now(),
+		Meta:         meta,
+	})
+}
+
+// writeDeleteMarker 添加删除标记，调用方须持有写锁
+func (r *local) writeDeleteMarker(key string) error {
+	if err := os.MkdirAll(r.getVersionDir(key), os.ModePerm); err != nil {
+		return err
+	}
+	now := r.now()
+	return r.writeVersionRecord(&versionRecord{
+		Key:          key,
+		VersionId:    newVersionId(),
+		LastModified: now,
+		Archived:     now,
+		DeleteMarker: true,
+	})
+}
+
+func (r *local) writeVersionRecord(record *versionRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(r.getVersionRecordPath(record.Key, record.VersionId), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// readVersionRecords 读取 key 的所有历史版本，按成为历史版本的时间从新到旧排列
+func (r *local) readVersionRecords(key string) ([]*versionRecord, error) {
+	entries, err := os.ReadDir(r.getVersionDir(key))
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	ret := make([]*versionRecord, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(r.getVersionDir(key), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		record := &versionRecord{}
+		if err := json.Unmarshal(data, record); err != nil {
+			return nil, err
+		}
+		ret = append(ret, record)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if !ret[i].Archived.Equal(ret[j].Archived) {
+			return ret[i].Archived.After(ret[j].Archived)
+		}
+		return ret[i].VersionId > ret[j].VersionId
+	})
+	return ret, nil
+}
+
+// removeVersionRecord 删除历史版本，版本目录为空时一并删除
+func (r *local) removeVersionRecord(key, versionId string) error {
+	if err := os.Remove(r.getVersionPath(key, versionId)); err != nil && !isNotFound(err) {
+		return err
+	}
+	if err := os.Remove(r.getVersionRecordPath(key, versionId)); err != nil && !isNotFound(err) {
+		return err
+	}
+	_ = os.Remove(r.getVersionDir(key))
+	return nil
+}
+
+// promoteVersion 当前版本不存在时，将最新的历史版本恢复为当前版本；最新的是删除标记时文件保持删除状态
+// 调用方须持有写锁
+func (r *local) promoteVersion(key string) error {
+	p := r.getSavePath(key)
+	if _, err := statObject(p); err == nil || !isNotFound(err) {
+		return err
+	}
+	records, err := r.readVersionRecords(key)
+	if err != nil || len(records) == 0 || records[0].DeleteMarker {
+		return err
+	}
+
+	latest := records[0]
+	if err := makeParentDir(p); err != nil {
+		return err
+	}
+	meta := latest.Meta
+	if meta == nil {
+		meta = &objectMeta{}
+	}
+	if latest.VersionId != oss.NullVersionId {
+		meta.VersionId = latest.VersionId
+	}
+	// 先链接再删除历史版本，写入 sidecar 失败时历史版本保持不变
+	err = r.placeObject(key, meta, func(dst string) error {
+		return linkOrCopyFile(r.getVersionPath(key, latest.VersionId), dst)
+	})
+	if err != nil {
+		return err
+	}
+	return r.removeVersionRecord(key, latest.VersionId)
+}
+
+// ListVersions 当前版本在前，其后为历史版本；未开启多版本时只有当前版本
+func (r *local) ListVersions(ctx context.Context, key string) (_ []*oss.ObjectVersion, err error) {
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ret := make([]*oss.ObjectVersion, 0)
+	currentId := ""
+	etag, exists, err := r.currentETag(key)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		info, err := statObject(r.getSavePath(key))
+		if err != nil {
+			return nil, err
+		}
+		meta, err := r.readMeta(key)
+		if err != nil {
+			return nil, err
+		}
+		currentId = currentVersionId(meta)
+		ret = append(ret, &oss.ObjectVersion{
+			Key:          key,
+			VersionId:    currentId,
+			IsLatest:     true,
+			Size:         info.Size(),
+			ETag:         etag,
+			LastModified: info.ModTime(),
+		})
+	}
+
+	records, err := r.readVersionRecords(key)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		// 归档后写入当前版本失败时会留下与当前版本相同的记录
+		if record.VersionId == currentId {
+			continue
+		}
+		version := &oss.ObjectVersion{
+			Key:            key,
+			VersionId:      record.VersionId,
+			IsLatest:       len(ret) == 0,
+			IsDeleteMarker: record.DeleteMarker,
+			Size:           record.Size,
+			LastModified:   record.LastModified,
+		}
+		if record.Meta != nil {
+			version.ETag = record.Meta.ETag
+		}
+		ret = append(ret, version)
+	}
+	return ret, nil
+}
+
+func (r *local) DownloadVersion(ctx context.Context, key, versionId string) (_ io.ReadCloser, err error) {
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, _, err := r.versionSource(key, versionId)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// versionSource 获取版本的文件路径及元数据，版本不存在或为删除标记时返回 fs.ErrNotExist，调用方须持有写锁
+func (r *local) versionSource(key, versionId string) (string, *objectMeta, error) {
+	notFound := &fs.PathError{Op: "open", Path: key + "?versionId=" + versionId, Err: fs.ErrNotExist}
+	if !validVersionId(versionId) {
+		return "", nil, notFound
+	}
+
+	p := r.getSavePath(key)
+	if _, err := statObject(p); err == nil {
+		meta, err := r.readMeta(key)
+		if err != nil {
+			return "", nil, err
+		}
+		if currentVersionId(meta) == versionId {
+			return p, meta, nil
+		}
+	}
+
+	data, err := os.ReadFile(r.getVersionRecordPath(key, versionId))
+	if err != nil {
+		if isNotFound(err) {
+			return "", nil, notFound
+		}
+		return "", nil, err
+	}
+	record := &versionRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return "", nil, err
+	}
+	if record.DeleteMarker {
+		return "", nil, notFound
+	}
+	meta := record.Meta
+	if meta == nil {
+		meta = &objectMeta{}
+	}
+	return r.getVersionPath(key, versionId), meta, nil
+}
+
+// DeleteVersion 删除当前版本或最新的删除标记后，将最新的历史版本恢复为当前版本
+func (r *local) DeleteVersion(ctx context.Context, key, versionId string) (err error) {
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return err
+	}
+	notFound := &fs.PathError{Op: "remove", Path: key + "?versionId=" + versionId, Err: fs.ErrNotExist}
+	if !validVersionId(versionId) {
+		return notFound
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p := r.getSavePath(key)
+	if _, err := statObject(p); err == nil {
+		meta, err := r.readMeta(key)
+		if err != nil {
+			return err
+		}
+		if currentVersionId(meta) == versionId {
+			if err := os.Remove(p); err != nil {
+				return err
+			}
+			if err := r.removeMeta(key); err != nil {
+				return err
+			}
+			// 可能残留的相同版本的记录
+			if err := r.removeVersionRecord(key, versionId); err != nil {
+				return err
+			}
+			if err := r.promoteVersion(key); err != nil {
+				return err
+			}
+			if r.pruneEmptyDirs {
+				r.pruneParentDirs(key)
+			}
+			return nil
+		}
+	}
+
+	if _, err := os.Stat(r.getVersionRecordPath(key, versionId)); err != nil {
+		if isNotFound(err) {
+			return notFound
+		}
+		return err
+	}
+	if err := r.removeVersionRecord(key, versionId); err != nil {
+		return err
+	}
+	return r.promoteVersion(key)
+}
+
+// RestoreVersion 与 s3 相同，复制指定版本生成新的当前版本
+func (r *local) RestoreVersion(ctx context.Context, key, versionId string) (err error) {
+	defer wrapError(&err)
+	key, err = r.checkKey(key)
+	if err != nil {
+		return err
+	}
+
+	// 持有写锁直到替换完成，避免版本文件在此期间被 DeleteVersion 等删除或重命名
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	src, meta, err := r.versionSource(key, versionId)
+	if err != nil {
+		return err
+	}
+
+	dst := r.getSavePath(key)
+	if err := makeParentDir(dst); err != nil {
+		return err
+	}
+	return r.replaceLocked(key, meta, oss.Precondition{}, func(dst string) error {
+		return linkOrCopyFile(src, dst)
+	})
+}
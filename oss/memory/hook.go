@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Hook 在每次调用存储前执行，返回非 nil 的错误时该调用直接返回此错误，用于注入故障及延迟
+// op 为 oss.Oss 的方法名，如 "Upload"、"UploadPart"；key 为调用的 key，DeleteMany、List 等为前缀或空
+type Hook func(ctx context.Context, op, key string) error
+
+// WithHook 添加 Hook，多个 Hook 按添加顺序执行
+func WithHook(hook Hook) Option {
+	return func(r *memory) {
+		r.hooks = append(r.hooks, hook)
+	}
+}
+
+// Fail 调用 ops 中的方法时返回 err，ops 为空时对所有方法生效
+func Fail(err error, ops ...string) Hook {
+	return func(ctx context.Context, op, key string) error {
+		if matchOp(op, ops) {
+			return err
+		}
+		return nil
+	}
+}
+
+// FailTimes 前 n 次调用 ops 中的方法时返回 err，之后正常执行，用于测试重试
+func FailTimes(n int, err error, ops ...string) Hook {
+	var calls int64
+	return func(ctx context.Context, op, key string) error {
+		if matchOp(op, ops) && atomic.AddInt64(&calls, 1) <= int64(n) {
+			return err
+		}
+		return nil
+	}
+}
+
+// Latency 调用 ops 中的方法前等待 d，期间 ctx 取消时返回 ctx.Err()
+func Latency(d time.Duration, ops ...string) Hook {
+	return func(ctx context.Context, op, key string) error {
+		if !matchOp(op, ops) {
+			return nil
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		}
+	}
+}
+
+func matchOp(op string, ops []string) bool {
+	if len(ops) == 0 {
+		return true
+	}
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// before 执行 Hook，ctx 已取消时直接返回
+func (r *memory) before(ctx context.Context, op, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, hook := range r.hooks {
+		if err := hook(ctx, op, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,605 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blues120/ias-kit/oss"
+)
+
+// object 文件的一个版本
+type object struct {
+	data         []byte
+	etag         string
+	lastModified time.Time
+	meta         objectMeta
+
+	// versionId 开启多版本后写入的版本的 id，未开启时为空
+	versionId    string
+	deleteMarker bool
+}
+
+// objectMeta 上传参数中保存在文件上的部分
+type objectMeta struct {
+	contentType        string
+	contentDisposition string
+	cacheControl       string
+	contentEncoding    string
+	acl                string
+	metadata           map[string]string
+	tags               map[string]string
+	expires            time.Time
+}
+
+func newObjectMeta(o *oss.UploadOptions) objectMeta {
+	return objectMeta{
+		contentType:        o.ContentType,
+		contentDisposition: o.ContentDisposition,
+		cacheControl:       o.CacheControl,
+		contentEncoding:    o.ContentEncoding,
+		acl:                o.ACL,
+		metadata:           cloneMap(o.Metadata),
+		tags:               cloneMap(o.Tags),
+		expires:            o.Expires,
+	}
+}
+
+// clone 复制元数据，避免修改标签等时影响其他版本
+func (m objectMeta) clone() objectMeta {
+	m.metadata = cloneMap(m.metadata)
+	m.tags = cloneMap(m.tags)
+	return m
+}
+
+func cloneMap(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	ret := make(map[string]string, len(m))
+	for k, v := range m {
+		ret[k] = v
+	}
+	return ret
+}
+
+type memory struct {
+	// mu 保护以下所有字段
+	mu sync.Mutex
+
+	// versions 每个 key 的所有版本，最后一个为最新的版本；未开启多版本时只有一个版本
+	// 版本的数据写入后不再修改，下载时直接读取
+	versions map[string][]*object
+	uploads  map[string]*multipartUpload
+
+	versioning bool
+	baseUrl    string
+	hooks      []Hook
+	now        func() time.Time
+}
+
+type Option func(*memory)
+
+// WithVersioning 开启多版本，被覆盖或删除的版本保留在内存中
+func WithVersioning() Option {
+	return func(r *memory) {
+		r.versioning = true
+	}
+}
+
+// WithBaseUrl 设置生成链接的前缀，默认为 "memory://oss"
+// 生成的链接仅用于比较，没有对应的 http 服务
+func WithBaseUrl(baseUrl string) Option {
+	return func(r *memory) {
+		r.baseUrl = baseUrl
+	}
+}
+
+// NewMemory 创建内存存储，用于单元测试，所有数据在进程退出后丢失
+// 行为与 local 相同，如删除不存在的文件时不返回错误
+func NewMemory(opts ...Option) oss.Oss {
+	r := &memory{
+		versions: make(map[string][]*object),
+		uploads:  make(map[string]*multipartUpload),
+		baseUrl:  "memory://oss",
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func notFound(key string) error {
+	return fmt.Errorf("%w: %q", oss.ErrNotFound, key)
+}
+
+// current 获取当前版本，不存在、已删除或已过期时返回 nil，调用方须持有锁
+func (r *memory) current(key string) *object {
+	versions := r.versions[key]
+	if len(versions) == 0 {
+		return nil
+	}
+	obj := versions[len(versions)-1]
+	if obj.deleteMarker || (!obj.meta.expires.IsZero() && !r.now().Before(obj.meta.expires)) {
+		return nil
+	}
+	return obj
+}
+
+// put 写入新的当前版本，未开启多版本时替换原有版本，调用方须持有锁
+func (r *memory) put(key string, obj *object) {
+	obj.lastModified = r.now()
+	if !r.versioning {
+		obj.versionId = ""
+		r.versions[key] = []*object{obj}
+		return
+	}
+	obj.versionId = newVersionId()
+	r.versions[key] = append(r.versions[key], obj)
+}
+
+// remove 删除当前版本，开启多版本时添加删除标记，调用方须持有锁
+func (r *memory) remove(key string) error {
+	if r.current(key) == nil {
+		return notFound(key)
+	}
+	if !r.versioning {
+		delete(r.versions, key)
+		return nil
+	}
+	r.versions[key] = append(r.versions[key], &object{
+		lastModified: r.now(),
+		versionId:    newVersionId(),
+		deleteMarker: true,
+	})
+	return nil
+}
+
+// checkPrecondition 根据当前版本的 ETag 检查条件，调用方须持有锁
+func (r *memory) checkPrecondition(key string, cond oss.Precondition) error {
+	if cond.IsZero() {
+		return nil
+	}
+	if obj := r.current(key); obj != nil {
+		return cond.Check(obj.etag, true)
+	}
+	return cond.Check("", false)
+}
+
+func (r *memory) Upload(ctx context.Context, key string, reader io.Reader, opts ...oss.UploadOption) error {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return err
+	}
+	o := oss.NewUploadOptions(opts...)
+	if err := oss.ValidateTags(o.Tags); err != nil {
+		return err
+	}
+	if err := r.before(ctx, "Upload", key); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.checkPrecondition(key, o.Precondition); err != nil {
+		return err
+	}
+	sum := md5.Sum(data)
+	r.put(key, &object{data: data, etag: oss.MD5ETag(sum[:]), meta: newObjectMeta(o)})
+	return nil
+}
+
+func (r *memory) Download(ctx context.Context, key string, opts ...oss.DownloadOption) (io.ReadCloser, error) {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.before(ctx, "Download", key); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	obj := r.current(key)
+	if obj == nil {
+		return nil, notFound(key)
+	}
+	if err := oss.NewDownloadOptions(opts...).Check(obj.etag, true); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+func (r *memory) DownloadRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.before(ctx, "DownloadRange", key); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	obj := r.current(key)
+	if obj == nil {
+		return nil, notFound(key)
+	}
+	size := int64(len(obj.data))
+	if offset < 0 || offset >= size {
+		return nil, oss.ErrInvalidRange
+	}
+	// 不使用 offset+length 比较，避免 length 很大时溢出
+	if length <= 0 || length > size-offset {
+		length = size - offset
+	}
+	return io.NopCloser(bytes.NewReader(obj.data[offset : offset+length])), nil
+}
+
+func (r *memory) Delete(ctx context.Context, key string) error {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return err
+	}
+	if err := r.before(ctx, "Delete", key); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current(key) == nil {
+		return nil
+	}
+	return r.remove(key)
+}
+
+// DeleteMany 不存在的文件视为删除成功，Hook 对整个请求执行一次
+func (r *memory) DeleteMany(ctx context.Context, keys []string) (*oss.DeleteResult, error) {
+	if err := r.before(ctx, "DeleteMany", ""); err != nil {
+		return nil, err
+	}
+	ret := &oss.DeleteResult{
+		Deleted: make([]string, 0, len(keys)),
+		Errors:  make([]*oss.DeleteError, 0),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range keys {
+		normalized, err := oss.NormalizeKey(key)
+		if err != nil {
+			ret.Errors = append(ret.Errors, &oss.DeleteError{Key: key, Err: err})
+			continue
+		}
+		if r.current(normalized) != nil {
+			_ = r.remove(normalized)
+		}
+		ret.Deleted = append(ret.Deleted, key)
+	}
+	return ret, nil
+}
+
+func (r *memory) DeletePrefix(ctx context.Context, prefix string) (*oss.DeleteResult, error) {
+	if prefix == "" {
+		return nil, oss.ErrEmptyPrefix
+	}
+	if err := oss.ValidatePrefix(prefix); err != nil {
+		return nil, err
+	}
+	if err := r.before(ctx, "DeletePrefix", prefix); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ret := &oss.DeleteResult{
+		Deleted: make([]string, 0),
+		Errors:  make([]*oss.DeleteError, 0),
+	}
+	for _, key := range r.keys(prefix) {
+		_ = r.remove(key)
+		ret.Deleted = append(ret.Deleted, key)
+	}
+	return ret, nil
+}
+
+// keys 获取以 prefix 开头的所有存在的 key 并排序，调用方须持有锁
+func (r *memory) keys(prefix string) []string {
+	ret := make([]string, 0)
+	for key := range r.versions {
+		if strings.HasPrefix(key, prefix) && r.current(key) != nil {
+			ret = append(ret, key)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Copy 元数据及标签随文件一起复制
+func (r *memory) Copy(ctx context.Context, srcKey, dstKey string) error {
+	srcKey, dstKey, err := normalizeKeys(srcKey, dstKey)
+	if err != nil {
+		return err
+	}
+	if err := r.before(ctx, "Copy", srcKey); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.copy(srcKey, dstKey)
+}
+
+// copy 调用方须持有锁
+func (r *memory) copy(srcKey, dstKey string) error {
+	src := r.current(srcKey)
+	if src == nil {
+		return notFound(srcKey)
+	}
+	if srcKey == dstKey {
+		return nil
+	}
+	r.put(dstKey, &object{data: src.data, etag: src.etag, meta: src.meta.clone()})
+	return nil
+}
+
+// Move 与开启多版本的 local 相同，复制后删除源文件
+func (r *memory) Move(ctx context.Context, srcKey, dstKey string) error {
+	srcKey, dstKey, err := normalizeKeys(srcKey, dstKey)
+	if err != nil {
+		return err
+	}
+	if err := r.before(ctx, "Move", srcKey); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.copy(srcKey, dstKey); err != nil || srcKey == dstKey {
+		return err
+	}
+	return r.remove(srcKey)
+}
+
+func normalizeKeys(srcKey, dstKey string) (string, string, error) {
+	srcKey, err := oss.NormalizeKey(srcKey)
+	if err != nil {
+		return "", "", err
+	}
+	dstKey, err = oss.NormalizeKey(dstKey)
+	if err != nil {
+		return "", "", err
+	}
+	return srcKey, dstKey, nil
+}
+
+func (r *memory) Exists(ctx context.Context, key string) (bool, error) {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return false, err
+	}
+	if err := r.before(ctx, "Exists", key); err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current(key) != nil, nil
+}
+
+// Stat 未设置 Content-Type 时与 local 相同，根据扩展名推断
+func (r *memory) Stat(ctx context.Context, key string) (*oss.ObjectInfo, error) {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.before(ctx, "Stat", key); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	obj := r.current(key)
+	if obj == nil {
+		return nil, notFound(key)
+	}
+	contentType := obj.meta.contentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return &oss.ObjectInfo{
+		Key:                key,
+		Size:               int64(len(obj.data)),
+		ETag:               obj.etag,
+		LastModified:       obj.lastModified,
+		ContentType:        contentType,
+		ContentDisposition: obj.meta.contentDisposition,
+		CacheControl:       obj.meta.cacheControl,
+		ContentEncoding:    obj.meta.contentEncoding,
+		Metadata:           cloneMap(obj.meta.metadata),
+		VersionId:          obj.versionId,
+	}, nil
+}
+
+func (r *memory) GetTags(ctx context.Context, key string) (map[string]string, error) {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.before(ctx, "GetTags", key); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	obj := r.current(key)
+	if obj == nil {
+		return nil, notFound(key)
+	}
+	tags := make(map[string]string, len(obj.meta.tags))
+	for k, v := range obj.meta.tags {
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+// SetTags 与 s3 相同，修改标签不会生成新的版本
+func (r *memory) SetTags(ctx context.Context, key string, tags map[string]string) error {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return err
+	}
+	if err := oss.ValidateTags(tags); err != nil {
+		return err
+	}
+	if err := r.before(ctx, "SetTags", key); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	obj := r.current(key)
+	if obj == nil {
+		return notFound(key)
+	}
+	obj.meta.tags = cloneMap(tags)
+	return nil
+}
+
+// List ContinuationToken 与 local 相同，为上一页最后返回的 key 或公共前缀
+func (r *memory) List(ctx context.Context, prefix string, opts *oss.ListOptions) (*oss.ListResult, error) {
+	if err := oss.ValidatePrefix(prefix); err != nil {
+		return nil, err
+	}
+	if err := r.before(ctx, "List", prefix); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := r.keys(prefix)
+	objects := make([]*oss.ObjectInfo, 0, len(keys))
+	for _, key := range keys {
+		obj := r.current(key)
+		objects = append(objects, &oss.ObjectInfo{
+			Key:          key,
+			Size:         int64(len(obj.data)),
+			ETag:         obj.etag,
+			LastModified: obj.lastModified,
+		})
+	}
+	return oss.PaginateObjects(objects, prefix, opts), nil
+}
+
+func (r *memory) GenerateUrl(ctx context.Context, key string, expire time.Duration) (string, error) {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return "", err
+	}
+	if err := r.before(ctx, "GenerateUrl", key); err != nil {
+		return "", err
+	}
+	return r.signedUrl(key, expire, nil), nil
+}
+
+// GenerateTemporaryUrl 链接中包含过期时间，不校验文件是否存在
+func (r *memory) GenerateTemporaryUrl(ctx context.Context, key string, expire time.Duration) (string, error) {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return "", err
+	}
+	if expire > oss.MaxUrlExpire {
+		return "", oss.ErrExpireTooLong
+	}
+	if err := r.before(ctx, "GenerateTemporaryUrl", key); err != nil {
+		return "", err
+	}
+	return r.signedUrl(key, expire, nil), nil
+}
+
+// GeneratePermanentUrl 与 s3 相同，将文件设为公开读后返回不带过期时间的链接
+func (r *memory) GeneratePermanentUrl(ctx context.Context, key string) (string, error) {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return "", err
+	}
+	if err := r.before(ctx, "GeneratePermanentUrl", key); err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	obj := r.current(key)
+	if obj == nil {
+		return "", notFound(key)
+	}
+	obj.meta.acl = oss.AclPublicRead
+	return r.getUrl(key), nil
+}
+
+func (r *memory) GenerateUploadUrl(ctx context.Context, key string, expire time.Duration, opts ...oss.UploadOption) (string, error) {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return "", err
+	}
+	if expire > oss.MaxUrlExpire {
+		return "", oss.ErrExpireTooLong
+	}
+	if err := oss.ValidateTags(oss.NewUploadOptions(opts...).Tags); err != nil {
+		return "", err
+	}
+	if err := r.before(ctx, "GenerateUploadUrl", key); err != nil {
+		return "", err
+	}
+	return r.signedUrl(key, expire, url.Values{"method": {"PUT"}}), nil
+}
+
+func (r *memory) GenerateUploadPartUrl(ctx context.Context, key, uploadId string, partNumber int64, expire time.Duration) (string, error) {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return "", err
+	}
+	if expire > oss.MaxUrlExpire {
+		return "", oss.ErrExpireTooLong
+	}
+	if err := r.before(ctx, "GenerateUploadPartUrl", key); err != nil {
+		return "", err
+	}
+	query := url.Values{}
+	query.Set("method", "PUT")
+	query.Set("uploadId", uploadId)
+	query.Set("partNumber", strconv.FormatInt(partNumber, 10))
+	return r.signedUrl(key, expire, query), nil
+}
+
+// signedUrl 生成带过期时间的链接
+func (r *memory) signedUrl(key string, expire time.Duration, query url.Values) string {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("expires", strconv.FormatInt(r.now().Add(expire).Unix(), 10))
+	return r.getUrl(key) + "?" + query.Encode()
+}
+
+func (r *memory) getUrl(key string) string {
+	return strings.TrimSuffix(r.baseUrl, "/") + "/" + (&url.URL{Path: key}).EscapedPath()
+}
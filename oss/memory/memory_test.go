@@ -0,0 +1,213 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/blues120/ias-kit/oss"
+	"github.com/blues120/ias-kit/oss/osstest"
+	"github.com/stretchr/testify/require"
+)
+
+func requireContent(t *testing.T, store oss.Oss, key string, expected []byte) {
+	reader, err := store.Download(context.Background(), key)
+	require.NoError(t, err)
+	defer reader.Close()
+	actual, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+func TestMemory_Object(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemory()
+	data := []byte("123456")
+
+	require.NoError(t, store.Upload(ctx, "dir//a.txt", bytes.NewReader(data),
+		oss.WithMetadata(map[string]string{"owner": "a"}), oss.WithTags(map[string]string{"tenant": "a"})))
+	requireContent(t, store, "dir/a.txt", data)
+
+	info, err := store.Stat(ctx, "dir/a.txt")
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), info.Size)
+	require.Equal(t, `"e10adc3949ba59abbe56e057f20f883e"`, info.ETag)
+	require.Equal(t, "text/plain; charset=utf-8", info.ContentType)
+	require.Equal(t, map[string]string{"owner": "a"}, info.Metadata)
+
+	reader, err := store.DownloadRange(ctx, "dir/a.txt", 2, 2)
+	require.NoError(t, err)
+	actual, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, []byte("34"), actual)
+	reader, err = store.DownloadRange(ctx, "dir/a.txt", 2, math.MaxInt64)
+	require.NoError(t, err)
+	actual, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, []byte("3456"), actual)
+	_, err = store.DownloadRange(ctx, "dir/a.txt", 6, 0)
+	require.ErrorIs(t, err, oss.ErrInvalidRange)
+
+	_, err = store.Download(ctx, "dir/a.txt", oss.WithDownloadIfNoneMatch(info.ETag))
+	require.ErrorIs(t, err, oss.ErrPreconditionFailed)
+	require.ErrorIs(t, store.Upload(ctx, "dir/a.txt", bytes.NewReader(data), oss.WithIfNotExists()), oss.ErrPreconditionFailed)
+
+	require.NoError(t, store.Copy(ctx, "dir/a.txt", "dir/b.txt"))
+	tags, err := store.GetTags(ctx, "dir/b.txt")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"tenant": "a"}, tags)
+	require.NoError(t, store.Move(ctx, "dir/b.txt", "c.txt"))
+	requireContent(t, store, "c.txt", data)
+
+	result, err := store.List(ctx, "", &oss.ListOptions{Delimiter: "/", MaxKeys: 1})
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 1)
+	require.Equal(t, "c.txt", result.Objects[0].Key)
+	require.True(t, result.IsTruncated)
+	result, err = store.List(ctx, "", &oss.ListOptions{Delimiter: "/", ContinuationToken: result.NextContinuationToken})
+	require.NoError(t, err)
+	require.Equal(t, []string{"dir/"}, result.CommonPrefixes)
+	require.False(t, result.IsTruncated)
+
+	deleted, err := store.DeletePrefix(ctx, "dir/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"dir/a.txt"}, deleted.Deleted)
+
+	_, err = store.Stat(ctx, "dir/a.txt")
+	require.ErrorIs(t, err, oss.ErrNotFound)
+	require.NoError(t, store.Delete(ctx, "dir/a.txt"))
+	_, err = store.Download(ctx, "../a.txt")
+	require.ErrorIs(t, err, oss.ErrInvalidKey)
+}
+
+func TestMemory_Expires(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemory()
+	require.NoError(t, store.Upload(ctx, "expired", bytes.NewReader(nil), oss.WithExpires(time.Now())))
+	exists, err := store.Exists(ctx, "expired")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestMemory_MultipartUpload(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemory()
+
+	uploadId, err := store.CreateMultipartUpload(ctx, "key", oss.WithContentType("text/plain"))
+	require.NoError(t, err)
+	// 乱序上传，按分片编号合并
+	_, err = store.UploadPart(ctx, "key", uploadId, 2, bytes.NewReader([]byte("world")))
+	require.NoError(t, err)
+	_, err = store.CompleteMultipartUpload(ctx, "key", uploadId, 2)
+	require.Error(t, err)
+	_, err = store.UploadPart(ctx, "key", uploadId, 1, bytes.NewReader([]byte("hello ")))
+	require.NoError(t, err)
+
+	parts, err := store.ListParts(ctx, "key", uploadId, 0)
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	require.Equal(t, int64(1), parts[0].PartNumber)
+
+	etag, err := store.CompleteMultipartUpload(ctx, "key", uploadId, 2)
+	require.NoError(t, err)
+	require.Regexp(t, `^"[0-9a-f]{32}-2"$`, etag)
+	requireContent(t, store, "key", []byte("hello world"))
+	info, err := store.Stat(ctx, "key")
+	require.NoError(t, err)
+	require.Equal(t, "text/plain", info.ContentType)
+
+	require.ErrorIs(t, store.AbortMultipartUpload(ctx, "key", uploadId), oss.ErrUploadNotFound)
+	uploads, err := store.ListMultipartUploads(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, uploads)
+}
+
+func TestMemory_Versioning(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemory(WithVersioning())
+	key := "versioned"
+
+	require.NoError(t, store.Upload(ctx, key, bytes.NewReader([]byte("v1"))))
+	require.NoError(t, store.Upload(ctx, key, bytes.NewReader([]byte("v2"))))
+	require.NoError(t, store.Delete(ctx, key))
+
+	versions, err := store.ListVersions(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	require.True(t, versions[0].IsDeleteMarker)
+	require.True(t, versions[0].IsLatest)
+
+	// 删除删除标记后上一个版本成为当前版本
+	require.NoError(t, store.DeleteVersion(ctx, key, versions[0].VersionId))
+	requireContent(t, store, key, []byte("v2"))
+
+	require.NoError(t, store.RestoreVersion(ctx, key, versions[2].VersionId))
+	requireContent(t, store, key, []byte("v1"))
+	reader, err := store.DownloadVersion(ctx, key, versions[1].VersionId)
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v2"), data)
+}
+
+func TestMemory_Urls(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemory(WithBaseUrl("http://example.com/files/"))
+
+	u, err := store.GenerateTemporaryUrl(ctx, "a b.txt", time.Minute)
+	require.NoError(t, err)
+	require.Regexp(t, `^http://example.com/files/a%20b.txt\?expires=\d+$`, u)
+	_, err = store.GenerateTemporaryUrl(ctx, "a", oss.MaxUrlExpire+time.Second)
+	require.ErrorIs(t, err, oss.ErrExpireTooLong)
+
+	_, err = store.GeneratePermanentUrl(ctx, "a")
+	require.ErrorIs(t, err, oss.ErrNotFound)
+	require.NoError(t, store.Upload(ctx, "a", bytes.NewReader(nil)))
+	u, err = store.GeneratePermanentUrl(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, "http://example.com/files/a", u)
+}
+
+func TestMemory_Hooks(t *testing.T) {
+	ctx := context.Background()
+	errInjected := errors.New("injected")
+	store := NewMemory(
+		WithHook(Fail(errInjected, "Stat", "GenerateUrl")),
+		WithHook(FailTimes(2, errInjected, "UploadPart")),
+		WithHook(Latency(time.Hour, "Download")),
+	)
+
+	require.NoError(t, store.Upload(ctx, "key", bytes.NewReader([]byte("1"))))
+	_, err := store.Stat(ctx, "key")
+	require.ErrorIs(t, err, errInjected)
+	_, err = store.GenerateUrl(ctx, "key", time.Minute)
+	require.ErrorIs(t, err, errInjected)
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = store.Download(timeout, "key")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Uploader 重试后成功
+	data := bytes.Repeat([]byte{'x'}, 2*oss.MinPartSize)
+	uploader := oss.NewUploader(store, func(u *oss.Uploader) {
+		u.PartSize = oss.MinPartSize
+		u.RetryDelay = 0
+	})
+	require.NoError(t, uploader.Upload(ctx, "large", bytes.NewReader(data)))
+	exists, err := store.Exists(ctx, "large")
+	require.NoError(t, err)
+	require.True(t, exists)
+}
+
+func TestMemory_Conformance(t *testing.T) {
+	osstest.Run(t, NewMemory())
+}
+
+func TestMemory_VersioningConformance(t *testing.T) {
+	osstest.RunVersioning(t, NewMemory(WithVersioning()))
+}
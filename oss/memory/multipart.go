@@ -0,0 +1,213 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blues120/ias-kit/oss"
+	"github.com/google/uuid"
+)
+
+// maxPartNumber 与 s3 相同，分片编号最大为 10000
+const maxPartNumber = 10000
+
+type multipartUpload struct {
+	key       string
+	initiated time.Time
+	meta      objectMeta
+	parts     map[int64]*part
+}
+
+type part struct {
+	data []byte
+	etag string
+}
+
+// getUpload 获取分片上传，不存在或 key 不一致时返回 oss.ErrUploadNotFound，调用方须持有锁
+func (r *memory) getUpload(key, uploadId string) (*multipartUpload, error) {
+	upload, ok := r.uploads[uploadId]
+	if !ok || upload.key != key {
+		return nil, fmt.Errorf("%w: %s", oss.ErrUploadNotFound, uploadId)
+	}
+	return upload, nil
+}
+
+func (r *memory) CreateMultipartUpload(ctx context.Context, key string, opts ...oss.UploadOption) (string, error) {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return "", err
+	}
+	o := oss.NewUploadOptions(opts...)
+	if err := oss.ValidateTags(o.Tags); err != nil {
+		return "", err
+	}
+	if err := r.before(ctx, "CreateMultipartUpload", key); err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	uploadId := uuid.New().String()
+	r.uploads[uploadId] = &multipartUpload{
+		key:       key,
+		initiated: r.now(),
+		meta:      newObjectMeta(o),
+		parts:     make(map[int64]*part),
+	}
+	return uploadId, nil
+}
+
+func (r *memory) UploadPart(ctx context.Context, key, uploadId string, partNumber int64, reader io.ReadSeeker) (string, error) {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return "", err
+	}
+	if partNumber < 1 || partNumber > maxPartNumber {
+		return "", fmt.Errorf("the part number must be between 1 and %d", maxPartNumber)
+	}
+	if err := r.before(ctx, "UploadPart", key); err != nil {
+		return "", err
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	upload, err := r.getUpload(key, uploadId)
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum(data)
+	etag := oss.MD5ETag(sum[:])
+	upload.parts[partNumber] = &part{data: data, etag: etag}
+	return etag, nil
+}
+
+func (r *memory) AbortMultipartUpload(ctx context.Context, key, uploadId string) error {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return err
+	}
+	if err := r.before(ctx, "AbortMultipartUpload", key); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.getUpload(key, uploadId); err != nil {
+		return err
+	}
+	delete(r.uploads, uploadId)
+	return nil
+}
+
+// CompleteMultipartUpload 与 local 相同，分片 1 至 partsNum 须全部上传
+func (r *memory) CompleteMultipartUpload(ctx context.Context, key, uploadId string, partsNum int64) (string, error) {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return "", err
+	}
+	if err := r.before(ctx, "CompleteMultipartUpload", key); err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	upload, err := r.getUpload(key, uploadId)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	partETags := make([]string, 0, partsNum)
+	for i := int64(1); i <= partsNum; i++ {
+		p, ok := upload.parts[i]
+		if !ok {
+			return "", fmt.Errorf("part %d of upload %s is missing", i, uploadId)
+		}
+		buf.Write(p.data)
+		partETags = append(partETags, p.etag)
+	}
+
+	etag, err := oss.MultipartETag(partETags)
+	if err != nil {
+		return "", err
+	}
+	r.put(key, &object{data: buf.Bytes(), etag: etag, meta: upload.meta})
+	delete(r.uploads, uploadId)
+	return etag, nil
+}
+
+// ListParts 与 local 相同，返回编号不超过 maxParts 的分片，maxParts 为 0 时默认 1000
+func (r *memory) ListParts(ctx context.Context, key, uploadId string, maxParts int64) ([]*oss.CompletedPart, error) {
+	key, err := oss.NormalizeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.before(ctx, "ListParts", key); err != nil {
+		return nil, err
+	}
+	if maxParts == 0 {
+		maxParts = 1000
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	upload, err := r.getUpload(key, uploadId)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*oss.CompletedPart, 0, len(upload.parts))
+	for partNumber, p := range upload.parts {
+		if partNumber <= maxParts {
+			ret = append(ret, &oss.CompletedPart{PartNumber: partNumber, ETag: p.etag})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].PartNumber < ret[j].PartNumber
+	})
+	return ret, nil
+}
+
+func (r *memory) ListMultipartUploads(ctx context.Context, prefix string) ([]*oss.MultipartUpload, error) {
+	if err := oss.ValidatePrefix(prefix); err != nil {
+		return nil, err
+	}
+	if err := r.before(ctx, "ListMultipartUploads", prefix); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ret := make([]*oss.MultipartUpload, 0)
+	for uploadId, upload := range r.uploads {
+		if strings.HasPrefix(upload.key, prefix) {
+			ret = append(ret, &oss.MultipartUpload{
+				Key:       upload.key,
+				UploadId:  uploadId,
+				Initiated: upload.initiated,
+			})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Key != ret[j].Key {
+			return ret[i].Key < ret[j].Key
+		}
+		if !ret[i].Initiated.Equal(ret[j].Initiated) {
+			return ret[i].Initiated.Before(ret[j].Initiated)
+		}
+		return ret[i].UploadId < ret[j].UploadId
+	})
+	return ret, nil
+}
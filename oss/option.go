@@ -0,0 +1,210 @@
+package oss
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	AclPrivate         = "private"
+	AclPublicRead      = "public-read"
+	AclPublicReadWrite = "public-read-write"
+)
+
+// UploadOptions 上传参数，对应上传时的 http 头
+type UploadOptions struct {
+	ContentType        string
+	ContentDisposition string
+	CacheControl       string
+	ContentEncoding    string
+
+	// ACL 访问权限，如 AclPrivate、AclPublicRead
+	ACL string
+
+	// Metadata 用户自定义元数据，即 x-amz-meta-* 头，key 统一为小写
+	Metadata map[string]string
+
+	// Tags 标签，即 x-amz-tagging 头，与元数据不同，可以在上传后通过 SetTags 修改
+	Tags map[string]string
+
+	// Expires 过期时间，为零值时不过期
+	// 过期后文件立即不可见，之后被自动删除
+	// s3 不支持，返回 ErrNotSupported，须通过生命周期规则按前缀设置过期时间
+	Expires time.Time
+
+	// Precondition 条件上传，仅 Upload 生效
+	Precondition
+}
+
+type UploadOption func(*UploadOptions)
+
+// NewUploadOptions 合并上传参数，供 Oss 的实现使用
+func NewUploadOptions(opts ...UploadOption) *UploadOptions {
+	o := &UploadOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithContentType 设置 Content-Type，浏览器据此决定直接展示还是下载
+func WithContentType(contentType string) UploadOption {
+	return func(o *UploadOptions) {
+		o.ContentType = contentType
+	}
+}
+
+// WithContentDisposition 设置 Content-Disposition，如 `attachment; filename="a.pdf"`
+func WithContentDisposition(contentDisposition string) UploadOption {
+	return func(o *UploadOptions) {
+		o.ContentDisposition = contentDisposition
+	}
+}
+
+// WithCacheControl 设置 Cache-Control
+func WithCacheControl(cacheControl string) UploadOption {
+	return func(o *UploadOptions) {
+		o.CacheControl = cacheControl
+	}
+}
+
+// WithContentEncoding 设置 Content-Encoding
+func WithContentEncoding(contentEncoding string) UploadOption {
+	return func(o *UploadOptions) {
+		o.ContentEncoding = contentEncoding
+	}
+}
+
+// WithACL 设置访问权限
+func WithACL(acl string) UploadOption {
+	return func(o *UploadOptions) {
+		o.ACL = acl
+	}
+}
+
+// WithMetadata 设置用户自定义元数据，多次调用时合并
+func WithMetadata(metadata map[string]string) UploadOption {
+	return func(o *UploadOptions) {
+		if o.Metadata == nil {
+			o.Metadata = make(map[string]string, len(metadata))
+		}
+		for k, v := range metadata {
+			o.Metadata[strings.ToLower(k)] = v
+		}
+	}
+}
+
+// WithTags 设置标签，多次调用时合并，限制见 ValidateTags
+func WithTags(tags map[string]string) UploadOption {
+	return func(o *UploadOptions) {
+		if o.Tags == nil {
+			o.Tags = make(map[string]string, len(tags))
+		}
+		for k, v := range tags {
+			o.Tags[k] = v
+		}
+	}
+}
+
+// WithExpires 设置过期时间，过期后文件被自动删除
+func WithExpires(expires time.Time) UploadOption {
+	return func(o *UploadOptions) {
+		o.Expires = expires
+	}
+}
+
+// WithTTL 设置文件自上传起的有效期，过期后文件被自动删除
+func WithTTL(ttl time.Duration) UploadOption {
+	return WithExpires(time.Now().Add(ttl))
+}
+
+// WithIfMatch 仅当文件存在且 ETag 与 etag 相同时上传，用于乐观锁
+func WithIfMatch(etag string) UploadOption {
+	return func(o *UploadOptions) {
+		o.IfMatch = etag
+	}
+}
+
+// WithIfNoneMatch 仅当文件不存在或 ETag 与 etag 不同时上传，etag 为 "*" 时要求文件不存在
+func WithIfNoneMatch(etag string) UploadOption {
+	return func(o *UploadOptions) {
+		o.IfNoneMatch = etag
+	}
+}
+
+// WithIfNotExists 仅当文件不存在时上传，用于幂等写入，同 WithIfNoneMatch("*")
+func WithIfNotExists() UploadOption {
+	return WithIfNoneMatch("*")
+}
+
+// DownloadOptions 下载参数
+type DownloadOptions struct {
+	Precondition
+}
+
+type DownloadOption func(*DownloadOptions)
+
+// NewDownloadOptions 合并下载参数，供 Oss 的实现使用
+func NewDownloadOptions(opts ...DownloadOption) *DownloadOptions {
+	o := &DownloadOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithDownloadIfMatch 仅当文件的 ETag 与 etag 相同时下载
+func WithDownloadIfMatch(etag string) DownloadOption {
+	return func(o *DownloadOptions) {
+		o.IfMatch = etag
+	}
+}
+
+// WithDownloadIfNoneMatch 仅当文件的 ETag 与 etag 不同时下载
+func WithDownloadIfNoneMatch(etag string) DownloadOption {
+	return func(o *DownloadOptions) {
+		o.IfNoneMatch = etag
+	}
+}
+
+// Precondition 条件请求，对应 If-Match 及 If-None-Match 头，条件不满足时返回 ErrPreconditionFailed
+type Precondition struct {
+	// IfMatch 文件须存在且 ETag 与之相同，"*" 表示文件存在即可
+	IfMatch string
+
+	// IfNoneMatch 文件须不存在或 ETag 与之不同，"*" 表示文件须不存在
+	IfNoneMatch string
+}
+
+// IsZero 是否未设置条件
+func (p Precondition) IsZero() bool {
+	return p.IfMatch == "" && p.IfNoneMatch == ""
+}
+
+// Check 根据文件当前的 ETag 判断条件是否满足，供 Oss 的实现使用
+// exists 为 false 表示文件不存在，此时忽略 etag
+func (p Precondition) Check(etag string, exists bool) error {
+	if p.IfMatch != "" {
+		if !exists {
+			return fmt.Errorf("%w: the object does not exist", ErrPreconditionFailed)
+		}
+		if p.IfMatch != "*" && !etagEqual(p.IfMatch, etag) {
+			return fmt.Errorf("%w: the etag %s does not match %s", ErrPreconditionFailed, etag, p.IfMatch)
+		}
+	}
+	if p.IfNoneMatch != "" && exists {
+		if p.IfNoneMatch == "*" {
+			return fmt.Errorf("%w: the object already exists", ErrPreconditionFailed)
+		}
+		if etagEqual(p.IfNoneMatch, etag) {
+			return fmt.Errorf("%w: the etag %s matches %s", ErrPreconditionFailed, etag, p.IfNoneMatch)
+		}
+	}
+	return nil
+}
+
+// etagEqual 比较 ETag，忽略双引号
+func etagEqual(a, b string) bool {
+	return strings.Trim(a, `"`) == strings.Trim(b, `"`)
+}
@@ -0,0 +1,38 @@
+package oss
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrecondition_Check(t *testing.T) {
+	etag := `"abc"`
+	tests := []struct {
+		cond   Precondition
+		etag   string
+		exists bool
+		ok     bool
+	}{
+		{Precondition{}, "", false, true},
+		{Precondition{IfMatch: etag}, etag, true, true},
+		{Precondition{IfMatch: "abc"}, etag, true, true},
+		{Precondition{IfMatch: etag}, `"def"`, true, false},
+		{Precondition{IfMatch: etag}, "", false, false},
+		{Precondition{IfMatch: "*"}, etag, true, true},
+		{Precondition{IfMatch: "*"}, "", false, false},
+		{Precondition{IfNoneMatch: "*"}, "", false, true},
+		{Precondition{IfNoneMatch: "*"}, etag, true, false},
+		{Precondition{IfNoneMatch: etag}, etag, true, false},
+		{Precondition{IfNoneMatch: etag}, `"def"`, true, true},
+		{Precondition{IfNoneMatch: etag}, "", false, true},
+	}
+	for _, tt := range tests {
+		err := tt.cond.Check(tt.etag, tt.exists)
+		if tt.ok {
+			require.NoError(t, err, "%+v", tt)
+		} else {
+			require.ErrorIs(t, err, ErrPreconditionFailed, "%+v", tt)
+		}
+	}
+}
@@ -6,24 +6,157 @@ import (
 	"time"
 )
 
+// MaxUrlExpire 临时链接最长有效期
+const MaxUrlExpire = time.Hour * 24 * 7
+
 type CompletedPart struct {
 	PartNumber int64
 	ETag       string
 }
 
+// ObjectInfo 对象信息
+// 列举时只返回 Key、Size、ETag 及 LastModified
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+
+	ContentType        string
+	ContentDisposition string
+	CacheControl       string
+	ContentEncoding    string
+
+	// Metadata 用户自定义元数据，即 x-amz-meta-* 头，key 统一为小写
+	Metadata map[string]string
+
+	// VersionId 当前版本的 id，未开启多版本时为空
+	VersionId string
+}
+
+// MultipartUpload 进行中的分片上传
+type MultipartUpload struct {
+	Key      string
+	UploadId string
+
+	// Initiated 创建分片上传的时间
+	Initiated time.Time
+}
+
+// NullVersionId 未开启多版本时写入的文件的版本 id，与 s3 相同
+const NullVersionId = "null"
+
+// ObjectVersion 文件的一个历史版本
+type ObjectVersion struct {
+	Key       string
+	VersionId string
+
+	// IsLatest 是否为当前版本
+	IsLatest bool
+
+	// IsDeleteMarker 是否为删除标记，开启多版本后删除文件只会添加删除标记
+	IsDeleteMarker bool
+
+	Size         int64
+	ETag         string
+	LastModified time.Time
+}
+
+// ListOptions 列举对象参数
+type ListOptions struct {
+	// Delimiter 分隔符，如 "/"，设置后按“目录”归组，归组结果放在 CommonPrefixes 中
+	Delimiter string
+
+	// ContinuationToken 上一页返回的 NextContinuationToken，为空时从头开始列举
+	ContinuationToken string
+
+	// MaxKeys 单页最多返回的对象及公共前缀数量，为 0 时默认 1000
+	MaxKeys int64
+}
+
+// ListResult 列举对象结果
+type ListResult struct {
+	Objects []*ObjectInfo
+
+	// CommonPrefixes 按 Delimiter 归组后的公共前缀
+	CommonPrefixes []string
+
+	// IsTruncated 是否还有下一页
+	IsTruncated bool
+
+	// NextContinuationToken 获取下一页时传入 ListOptions.ContinuationToken
+	NextContinuationToken string
+}
+
+// DeleteResult 批量删除结果
+type DeleteResult struct {
+	// Deleted 删除成功的 key，不存在的 key 也视为删除成功
+	Deleted []string
+
+	// Errors 删除失败的 key 及原因
+	Errors []*DeleteError
+}
+
+// Oss 对象存储
+// 各实现返回的错误可以通过 errors.Is 与 ErrNotFound、ErrPermission 等比较，与具体实现无关
 type Oss interface {
 	// Upload 上传文件
-	Upload(ctx context.Context, key string, reader io.Reader) error
+	// opts 设置 Content-Type、ACL、用户元数据等，以及 WithIfMatch、WithIfNotExists 等上传条件
+	Upload(ctx context.Context, key string, reader io.Reader, opts ...UploadOption) error
 
 	// Download 下载文件
-	Download(ctx context.Context, key string) (io.ReadCloser, error)
+	// opts 设置 If-Match、If-None-Match 等条件，不满足时返回 ErrPreconditionFailed
+	Download(ctx context.Context, key string, opts ...DownloadOption) (io.ReadCloser, error)
+
+	// DownloadRange 下载文件的指定范围，用于视频播放、断点续传等场景
+	// length <= 0 时读取到文件末尾，超出文件末尾的部分会被截断
+	// offset 超出文件大小时返回 ErrInvalidRange
+	DownloadRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
 
-	// Delete 删除文件
+	// Delete 删除文件，文件不存在时不返回错误
 	Delete(ctx context.Context, key string) error
 
+	// DeleteMany 批量删除文件，单个文件删除失败不影响其他文件
+	// 返回的 error 仅表示请求本身失败，各文件的删除结果见 DeleteResult
+	DeleteMany(ctx context.Context, keys []string) (*DeleteResult, error)
+
+	// DeletePrefix 删除以 prefix 开头的所有文件，prefix 不能为空
+	DeletePrefix(ctx context.Context, prefix string) (*DeleteResult, error)
+
+	// Copy 服务端复制文件，目标文件已存在时覆盖，元数据随文件一起复制
+	Copy(ctx context.Context, srcKey, dstKey string) error
+
+	// Move 服务端移动文件，目标文件已存在时覆盖
+	Move(ctx context.Context, srcKey, dstKey string) error
+
 	// Exists 判断文件是否存在
 	Exists(ctx context.Context, key string) (bool, error)
 
+	// Stat 获取文件元数据
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+
+	// ListVersions 列举 key 的所有版本，包括删除标记，按时间从新到旧排列
+	ListVersions(ctx context.Context, key string) ([]*ObjectVersion, error)
+
+	// DownloadVersion 下载文件的指定版本
+	DownloadVersion(ctx context.Context, key, versionId string) (io.ReadCloser, error)
+
+	// DeleteVersion 永久删除文件的指定版本，删除当前版本时上一个版本成为当前版本
+	DeleteVersion(ctx context.Context, key, versionId string) error
+
+	// RestoreVersion 将指定版本复制为新的当前版本，原当前版本保留在历史版本中
+	RestoreVersion(ctx context.Context, key, versionId string) error
+
+	// GetTags 获取文件的标签
+	GetTags(ctx context.Context, key string) (map[string]string, error)
+
+	// SetTags 设置文件的标签，替换原有的全部标签，tags 为空时删除全部标签
+	SetTags(ctx context.Context, key string, tags map[string]string) error
+
+	// List 按前缀分页列举文件
+	// opts 为 nil 时使用默认参数
+	List(ctx context.Context, prefix string, opts *ListOptions) (*ListResult, error)
+
 	// GenerateUrl 生成文件下载链接
 	// expire 链接过期时间
 	// Deprecated: use GenerateTemporaryUrl or GeneratePermanentUrl instead
@@ -36,8 +169,19 @@ type Oss interface {
 	// GeneratePermanentUrl 生成永久访问链接
 	GeneratePermanentUrl(ctx context.Context, key string) (string, error)
 
+	// GenerateUploadUrl 生成临时上传链接，客户端使用 PUT 方法直接上传文件
+	// expire 链接过期时间，最长有效期7天
+	// opts 中的 Content-Type、用户元数据等参与签名，客户端上传时须携带相同的请求头
+	GenerateUploadUrl(ctx context.Context, key string, expire time.Duration, opts ...UploadOption) (string, error)
+
+	// GenerateUploadPartUrl 生成分片的临时上传链接，uploadId 由 CreateMultipartUpload 获得
+	// 客户端使用 PUT 方法上传分片，全部上传后由服务端调用 CompleteMultipartUpload
+	// expire 链接过期时间，最长有效期7天
+	GenerateUploadPartUrl(ctx context.Context, key, uploadId string, partNumber int64, expire time.Duration) (string, error)
+
 	// 初始化分片上传，用于大文件分片上传
-	CreateMultipartUpload(ctx context.Context, key string) (uploadId string, err error)
+	// opts 与 Upload 相同，在完成分片上传后生效
+	CreateMultipartUpload(ctx context.Context, key string, opts ...UploadOption) (uploadId string, err error)
 
 	// 上传分片
 	UploadPart(ctx context.Context, key, uploadId string, partNumber int64, reader io.ReadSeeker) (etag string, err error)
@@ -50,4 +194,8 @@ type Oss interface {
 
 	// 列举已上传分片
 	ListParts(ctx context.Context, key, uploadId string, maxParts int64) (parts []*CompletedPart, err error)
+
+	// ListMultipartUploads 列举 key 以 prefix 开头的进行中的分片上传，即未完成也未终止的分片上传
+	// 按 key 及创建时间排序
+	ListMultipartUploads(ctx context.Context, prefix string) ([]*MultipartUpload, error)
 }
@@ -0,0 +1,585 @@
+// Package osstest 提供与具体实现无关的 oss.Oss 一致性测试
+//
+// 各实现在自己的测试中调用 Run，保证在相同的输入下与其他实现表现一致：
+//
+//	func TestConformance(t *testing.T) {
+//		store, err := NewLocal(t.TempDir(), "")
+//		require.NoError(t, err)
+//		osstest.Run(t, store)
+//	}
+package osstest
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blues120/ias-kit/oss"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// Run 对 store 执行一致性测试
+// 测试只使用随机前缀下的 key，结束后删除，可以在共用的存储上执行
+// 不测试多版本等依赖存储配置的功能，多版本见 RunVersioning
+func Run(t *testing.T, store oss.Oss) {
+	c := &conformance{
+		store:  store,
+		prefix: "osstest-" + uuid.New().String() + "/",
+	}
+	t.Cleanup(c.cleanup)
+
+	t.Run("MissingKey", c.testMissingKey)
+	t.Run("InvalidKey", c.testInvalidKey)
+	t.Run("EmptyBody", c.testEmptyBody)
+	t.Run("ETag", c.testETag)
+	t.Run("Overwrite", c.testOverwrite)
+	t.Run("NestedKey", c.testNestedKey)
+	t.Run("DownloadRange", c.testDownloadRange)
+	t.Run("Metadata", c.testMetadata)
+	t.Run("CopyAndMove", c.testCopyAndMove)
+	t.Run("Tags", c.testTags)
+	t.Run("Expires", c.testExpires)
+	t.Run("Precondition", c.testPrecondition)
+	t.Run("List", c.testList)
+	t.Run("DeleteMany", c.testDeleteMany)
+	t.Run("MultipartOrdering", c.testMultipartOrdering)
+	t.Run("AbortMultipartUpload", c.testAbortMultipartUpload)
+	t.Run("Urls", c.testUrls)
+}
+
+// RunVersioning 对开启多版本的 store 执行多版本相关的一致性测试
+// 测试结束后删除写入的所有版本
+func RunVersioning(t *testing.T, store oss.Oss) {
+	c := &conformance{
+		store:  store,
+		prefix: "osstest-" + uuid.New().String() + "/",
+	}
+
+	t.Run("RestoreVersion", c.testRestoreVersion)
+	t.Run("RestoreCurrentVersion", c.testRestoreCurrentVersion)
+}
+
+type conformance struct {
+	store  oss.Oss
+	prefix string
+}
+
+// key 获取测试前缀下的 key
+func (c *conformance) key(name string) string {
+	return c.prefix + name
+}
+
+// cleanup 删除测试写入的文件及未完成的分片上传
+func (c *conformance) cleanup() {
+	ctx := context.Background()
+	uploads, _ := c.store.ListMultipartUploads(ctx, c.prefix)
+	for _, upload := range uploads {
+		_ = c.store.AbortMultipartUpload(ctx, upload.Key, upload.UploadId)
+	}
+	_, _ = c.store.DeletePrefix(ctx, c.prefix)
+}
+
+// cleanupVersions 测试结束后删除 key 的所有版本及删除标记
+func (c *conformance) cleanupVersions(t *testing.T, key string) {
+	t.Cleanup(func() {
+		ctx := context.Background()
+		versions, _ := c.store.ListVersions(ctx, key)
+		for _, v := range versions {
+			_ = c.store.DeleteVersion(ctx, key, v.VersionId)
+		}
+	})
+}
+
+func (c *conformance) upload(t *testing.T, key string, data []byte, opts ...oss.UploadOption) {
+	require.NoError(t, c.store.Upload(context.Background(), key, bytes.NewReader(data), opts...))
+}
+
+func (c *conformance) requireContent(t *testing.T, key string, expected []byte) {
+	reader, err := c.store.Download(context.Background(), key)
+	require.NoError(t, err)
+	defer reader.Close()
+	actual, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(expected, actual), "content of %q does not match", key)
+}
+
+func (c *conformance) requireNotExists(t *testing.T, key string) {
+	exists, err := c.store.Exists(context.Background(), key)
+	require.NoError(t, err)
+	require.False(t, exists, "%q should not exist", key)
+}
+
+// urlStatus 访问链接并返回状态码
+func urlStatus(url string) (int, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+func md5ETag(data []byte) string {
+	sum := md5.Sum(data)
+	return oss.MD5ETag(sum[:])
+}
+
+func randomData(size int) []byte {
+	data := make([]byte, size)
+	rand.Read(data)
+	return data
+}
+
+func (c *conformance) testMissingKey(t *testing.T) {
+	ctx := context.Background()
+	key := c.key("missing")
+
+	_, err := c.store.Download(ctx, key)
+	require.ErrorIs(t, err, oss.ErrNotFound)
+	_, err = c.store.DownloadRange(ctx, key, 0, 1)
+	require.ErrorIs(t, err, oss.ErrNotFound)
+	_, err = c.store.Stat(ctx, key)
+	require.ErrorIs(t, err, oss.ErrNotFound)
+	_, err = c.store.GetTags(ctx, key)
+	require.ErrorIs(t, err, oss.ErrNotFound)
+	require.ErrorIs(t, c.store.Copy(ctx, key, c.key("dst")), oss.ErrNotFound)
+	require.ErrorIs(t, c.store.Move(ctx, key, c.key("dst")), oss.ErrNotFound)
+	c.requireNotExists(t, key)
+	c.requireNotExists(t, c.key("dst"))
+
+	// 与 s3 相同，删除不存在的文件时成功
+	require.NoError(t, c.store.Delete(ctx, key))
+}
+
+func (c *conformance) testInvalidKey(t *testing.T) {
+	ctx := context.Background()
+	// s3 的 key 不做规范化，"/absolute"、"trailing/" 等是否合法由各实现决定
+	for _, key := range []string{"", "../escape", c.key("a/../../b"), c.key("a\x00b")} {
+		require.ErrorIs(t, c.store.Upload(ctx, key, bytes.NewReader(nil)), oss.ErrInvalidKey, key)
+		_, err := c.store.Download(ctx, key)
+		require.ErrorIs(t, err, oss.ErrInvalidKey, key)
+		_, err = c.store.Stat(ctx, key)
+		require.ErrorIs(t, err, oss.ErrInvalidKey, key)
+	}
+}
+
+func (c *conformance) testEmptyBody(t *testing.T) {
+	ctx := context.Background()
+	key := c.key("empty")
+	c.upload(t, key, nil)
+	c.requireContent(t, key, []byte{})
+
+	info, err := c.store.Stat(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), info.Size)
+	require.Equal(t, md5ETag(nil), info.ETag)
+
+	exists, err := c.store.Exists(ctx, key)
+	require.NoError(t, err)
+	require.True(t, exists)
+}
+
+func (c *conformance) testETag(t *testing.T) {
+	ctx := context.Background()
+	key := c.key("etag/a")
+	data := []byte("hello")
+	c.upload(t, key, data)
+
+	info, err := c.store.Stat(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, md5ETag(data), info.ETag)
+	require.Equal(t, int64(len(data)), info.Size)
+	require.False(t, info.LastModified.IsZero())
+
+	result, err := c.store.List(ctx, c.key("etag/"), nil)
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 1)
+	require.Equal(t, key, result.Objects[0].Key)
+	require.Equal(t, info.ETag, result.Objects[0].ETag)
+	require.Equal(t, info.Size, result.Objects[0].Size)
+}
+
+func (c *conformance) testOverwrite(t *testing.T) {
+	key := c.key("overwrite")
+	c.upload(t, key, []byte("longer content"))
+	c.upload(t, key, []byte("short"))
+	c.requireContent(t, key, []byte("short"))
+}
+
+func (c *conformance) testNestedKey(t *testing.T) {
+	ctx := context.Background()
+	key := c.key("nested/a/b/c.txt")
+	c.upload(t, key, []byte("1"))
+	c.requireContent(t, key, []byte("1"))
+
+	// 上级“目录”不是文件
+	for _, parent := range []string{c.key("nested/a"), c.key("nested/a/b")} {
+		c.requireNotExists(t, parent)
+		_, err := c.store.Stat(ctx, parent)
+		require.ErrorIs(t, err, oss.ErrNotFound)
+		_, err = c.store.Download(ctx, parent)
+		require.ErrorIs(t, err, oss.ErrNotFound)
+	}
+	// 上级是文件
+	_, err := c.store.Stat(ctx, key+"/child")
+	require.ErrorIs(t, err, oss.ErrNotFound)
+
+	result, err := c.store.List(ctx, c.key("nested/"), &oss.ListOptions{Delimiter: "/"})
+	require.NoError(t, err)
+	require.Empty(t, result.Objects)
+	require.Equal(t, []string{c.key("nested/a/")}, result.CommonPrefixes)
+
+	require.NoError(t, c.store.Delete(ctx, key))
+	c.requireNotExists(t, key)
+	result, err = c.store.List(ctx, c.key("nested/"), nil)
+	require.NoError(t, err)
+	require.Empty(t, result.Objects)
+}
+
+func (c *conformance) testDownloadRange(t *testing.T) {
+	ctx := context.Background()
+	key := c.key("range")
+	c.upload(t, key, []byte("0123456789"))
+
+	for _, tc := range []struct {
+		offset, length int64
+		expected       string
+	}{
+		{0, 1, "0"},
+		{2, 3, "234"},
+		{7, 0, "789"},
+		{7, 100, "789"},
+		{7, math.MaxInt64, "789"},
+		{9, 1, "9"},
+	} {
+		reader, err := c.store.DownloadRange(ctx, key, tc.offset, tc.length)
+		require.NoError(t, err)
+		actual, err := io.ReadAll(reader)
+		reader.Close()
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, string(actual), "offset %d length %d", tc.offset, tc.length)
+	}
+
+	_, err := c.store.DownloadRange(ctx, key, 10, 1)
+	require.ErrorIs(t, err, oss.ErrInvalidRange)
+	_, err = c.store.DownloadRange(ctx, key, -1, 1)
+	require.ErrorIs(t, err, oss.ErrInvalidRange)
+}
+
+func (c *conformance) testMetadata(t *testing.T) {
+	ctx := context.Background()
+	key := c.key("metadata.bin")
+	c.upload(t, key, []byte("1"),
+		oss.WithContentType("text/csv"),
+		oss.WithContentDisposition(`attachment; filename="a.csv"`),
+		oss.WithCacheControl("max-age=60"),
+		oss.WithMetadata(map[string]string{"owner": "osstest"}),
+	)
+
+	info, err := c.store.Stat(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, "text/csv", info.ContentType)
+	require.Equal(t, `attachment; filename="a.csv"`, info.ContentDisposition)
+	require.Equal(t, "max-age=60", info.CacheControl)
+	require.Equal(t, map[string]string{"owner": "osstest"}, info.Metadata)
+}
+
+func (c *conformance) testCopyAndMove(t *testing.T) {
+	ctx := context.Background()
+	src, copied, moved := c.key("copy/src"), c.key("copy/copied"), c.key("copy/moved")
+	data := []byte("copy")
+	c.upload(t, src, data, oss.WithContentType("text/plain"))
+
+	require.NoError(t, c.store.Copy(ctx, src, copied))
+	c.requireContent(t, src, data)
+	c.requireContent(t, copied, data)
+	info, err := c.store.Stat(ctx, copied)
+	require.NoError(t, err)
+	require.Equal(t, "text/plain", info.ContentType)
+
+	// 目标已存在时覆盖
+	c.upload(t, moved, []byte("old"))
+	require.NoError(t, c.store.Move(ctx, copied, moved))
+	c.requireNotExists(t, copied)
+	c.requireContent(t, moved, data)
+}
+
+func (c *conformance) testTags(t *testing.T) {
+	ctx := context.Background()
+	key := c.key("tags")
+	tags := map[string]string{"tenant": "a"}
+	c.upload(t, key, []byte("1"), oss.WithTags(tags))
+
+	actual, err := c.store.GetTags(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, tags, actual)
+
+	require.NoError(t, c.store.SetTags(ctx, key, nil))
+	actual, err = c.store.GetTags(ctx, key)
+	require.NoError(t, err)
+	require.Empty(t, actual)
+
+	tooLong := map[string]string{"k": string(make([]byte, oss.MaxTagValueLength+1))}
+	require.ErrorIs(t, c.store.SetTags(ctx, key, tooLong), oss.ErrInvalidTags)
+}
+
+// testExpires 过期的文件在被删除前即不可见，不支持过期时间的实现须返回 ErrNotSupported
+func (c *conformance) testExpires(t *testing.T) {
+	ctx := context.Background()
+	key := c.key("expires")
+	err := c.store.Upload(ctx, key, bytes.NewReader([]byte("1")), oss.WithExpires(time.Now().Add(-time.Second)))
+	if errors.Is(err, oss.ErrNotSupported) {
+		t.Skip("expires is not supported")
+	}
+	require.NoError(t, err)
+
+	exists, err := c.store.Exists(ctx, key)
+	require.NoError(t, err)
+	require.False(t, exists)
+	_, err = c.store.Stat(ctx, key)
+	require.ErrorIs(t, err, oss.ErrNotFound)
+	_, err = c.store.Download(ctx, key)
+	require.ErrorIs(t, err, oss.ErrNotFound)
+	_, err = c.store.GetTags(ctx, key)
+	require.ErrorIs(t, err, oss.ErrNotFound)
+	list, err := c.store.List(ctx, key, nil)
+	require.NoError(t, err)
+	require.Empty(t, list.Objects)
+}
+
+func (c *conformance) testPrecondition(t *testing.T) {
+	ctx := context.Background()
+	key := c.key("precondition")
+	data := []byte("1")
+	c.upload(t, key, data, oss.WithIfNotExists())
+	require.ErrorIs(t, c.store.Upload(ctx, key, bytes.NewReader(data), oss.WithIfNotExists()), oss.ErrPreconditionFailed)
+	require.ErrorIs(t, c.store.Upload(ctx, key, bytes.NewReader(data), oss.WithIfMatch(md5ETag([]byte("2")))), oss.ErrPreconditionFailed)
+	c.upload(t, key, []byte("2"), oss.WithIfMatch(md5ETag(data)))
+
+	_, err := c.store.Download(ctx, key, oss.WithDownloadIfMatch(md5ETag(data)))
+	require.ErrorIs(t, err, oss.ErrPreconditionFailed)
+	reader, err := c.store.Download(ctx, key, oss.WithDownloadIfMatch(md5ETag([]byte("2"))))
+	require.NoError(t, err)
+	reader.Close()
+}
+
+func (c *conformance) testList(t *testing.T) {
+	ctx := context.Background()
+	prefix := c.key("list/")
+	keys := []string{prefix + "a", prefix + "b", prefix + "c/1", prefix + "c/2", prefix + "d"}
+	for _, key := range keys {
+		c.upload(t, key, []byte("1"))
+	}
+
+	// 分页获取全部文件
+	actual := make([]string, 0)
+	opts := &oss.ListOptions{MaxKeys: 2}
+	for {
+		result, err := c.store.List(ctx, prefix, opts)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(result.Objects), 2)
+		for _, obj := range result.Objects {
+			actual = append(actual, obj.Key)
+		}
+		if !result.IsTruncated {
+			break
+		}
+		opts.ContinuationToken = result.NextContinuationToken
+	}
+	require.Equal(t, keys, actual)
+
+	result, err := c.store.List(ctx, prefix, &oss.ListOptions{Delimiter: "/"})
+	require.NoError(t, err)
+	require.Len(t, result.Objects, 3)
+	require.Equal(t, []string{prefix + "c/"}, result.CommonPrefixes)
+
+	result, err = c.store.List(ctx, c.key("list-missing/"), nil)
+	require.NoError(t, err)
+	require.Empty(t, result.Objects)
+	require.False(t, result.IsTruncated)
+}
+
+func (c *conformance) testDeleteMany(t *testing.T) {
+	ctx := context.Background()
+	a, b, missing := c.key("delete/a"), c.key("delete/b"), c.key("delete/missing")
+	c.upload(t, a, []byte("1"))
+	c.upload(t, b, []byte("1"))
+
+	result, err := c.store.DeleteMany(ctx, []string{a, missing})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{a, missing}, result.Deleted)
+	require.Empty(t, result.Errors)
+	c.requireNotExists(t, a)
+
+	_, err = c.store.DeletePrefix(ctx, "")
+	require.ErrorIs(t, err, oss.ErrEmptyPrefix)
+	result, err = c.store.DeletePrefix(ctx, c.key("delete/"))
+	require.NoError(t, err)
+	require.Equal(t, []string{b}, result.Deleted)
+	c.requireNotExists(t, b)
+}
+
+func (c *conformance) testMultipartOrdering(t *testing.T) {
+	ctx := context.Background()
+	key := c.key("multipart/ordered")
+	// s3 要求除最后一个分片外不小于 5MB
+	part1, part2, part3 := randomData(oss.MinPartSize), randomData(oss.MinPartSize), []byte("tail")
+
+	uploadId, err := c.store.CreateMultipartUpload(ctx, key, oss.WithContentType("text/plain"))
+	require.NoError(t, err)
+	for _, p := range []struct {
+		number int64
+		data   []byte
+	}{{3, part3}, {1, part1}, {2, part2}} {
+		etag, err := c.store.UploadPart(ctx, key, uploadId, p.number, bytes.NewReader(p.data))
+		require.NoError(t, err)
+		require.Equal(t, md5ETag(p.data), etag)
+	}
+
+	parts, err := c.store.ListParts(ctx, key, uploadId, 0)
+	require.NoError(t, err)
+	require.Len(t, parts, 3)
+	for i, part := range parts {
+		require.Equal(t, int64(i+1), part.PartNumber)
+	}
+
+	uploads, err := c.store.ListMultipartUploads(ctx, c.key("multipart/"))
+	require.NoError(t, err)
+	require.Len(t, uploads, 1)
+	require.Equal(t, key, uploads[0].Key)
+	require.Equal(t, uploadId, uploads[0].UploadId)
+
+	etag, err := c.store.CompleteMultipartUpload(ctx, key, uploadId, 3)
+	require.NoError(t, err)
+	require.Regexp(t, `^"[0-9a-f]{32}-3"$`, etag)
+
+	expected := append(append(append([]byte{}, part1...), part2...), part3...)
+	c.requireContent(t, key, expected)
+	info, err := c.store.Stat(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, etag, info.ETag)
+	require.Equal(t, int64(len(expected)), info.Size)
+	require.Equal(t, "text/plain", info.ContentType)
+
+	uploads, err = c.store.ListMultipartUploads(ctx, c.key("multipart/"))
+	require.NoError(t, err)
+	require.Empty(t, uploads)
+}
+
+func (c *conformance) testAbortMultipartUpload(t *testing.T) {
+	ctx := context.Background()
+	key := c.key("multipart/aborted")
+
+	uploadId, err := c.store.CreateMultipartUpload(ctx, key)
+	require.NoError(t, err)
+	_, err = c.store.UploadPart(ctx, key, uploadId, 1, bytes.NewReader([]byte("1")))
+	require.NoError(t, err)
+	require.NoError(t, c.store.AbortMultipartUpload(ctx, key, uploadId))
+
+	_, err = c.store.UploadPart(ctx, key, uploadId, 2, bytes.NewReader([]byte("1")))
+	require.ErrorIs(t, err, oss.ErrUploadNotFound)
+	_, err = c.store.ListParts(ctx, key, uploadId, 0)
+	require.ErrorIs(t, err, oss.ErrUploadNotFound)
+	_, err = c.store.CompleteMultipartUpload(ctx, key, uploadId, 1)
+	require.ErrorIs(t, err, oss.ErrUploadNotFound)
+	require.ErrorIs(t, c.store.AbortMultipartUpload(ctx, key, uploadId), oss.ErrUploadNotFound)
+
+	uploads, err := c.store.ListMultipartUploads(ctx, key)
+	require.NoError(t, err)
+	require.Empty(t, uploads)
+	c.requireNotExists(t, key)
+}
+
+func (c *conformance) testUrls(t *testing.T) {
+	ctx := context.Background()
+	key := c.key("url")
+
+	_, err := c.store.GenerateTemporaryUrl(ctx, key, oss.MaxUrlExpire+time.Second)
+	require.ErrorIs(t, err, oss.ErrExpireTooLong)
+	_, err = c.store.GenerateUploadUrl(ctx, key, oss.MaxUrlExpire+time.Second)
+	require.ErrorIs(t, err, oss.ErrExpireTooLong)
+	_, err = c.store.GenerateTemporaryUrl(ctx, "../escape", time.Minute)
+	require.ErrorIs(t, err, oss.ErrInvalidKey)
+
+	// 过期时间参与签名
+	short, err := c.store.GenerateTemporaryUrl(ctx, key, time.Minute)
+	require.NoError(t, err)
+	long, err := c.store.GenerateTemporaryUrl(ctx, key, time.Hour)
+	require.NoError(t, err)
+	require.NotEqual(t, short, long)
+
+	uploadUrl, err := c.store.GenerateUploadUrl(ctx, key, time.Minute)
+	require.NoError(t, err)
+	require.NotEmpty(t, uploadUrl)
+
+	_, err = c.store.GeneratePermanentUrl(ctx, key)
+	require.ErrorIs(t, err, oss.ErrNotFound)
+
+	// 链接可以通过 http 访问时，检查过期的链接被拒绝
+	c.upload(t, key, []byte("1"))
+	expiring, err := c.store.GenerateTemporaryUrl(ctx, key, time.Second)
+	require.NoError(t, err)
+	if !strings.HasPrefix(expiring, "http://") && !strings.HasPrefix(expiring, "https://") {
+		return
+	}
+	status, err := urlStatus(expiring)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+	require.Eventually(t, func() bool {
+		status, err := urlStatus(expiring)
+		return err == nil && status == http.StatusForbidden
+	}, 5*time.Second, 200*time.Millisecond)
+}
+
+func (c *conformance) testRestoreVersion(t *testing.T) {
+	ctx := context.Background()
+	key := c.key("restore.txt")
+	c.cleanupVersions(t, key)
+	c.upload(t, key, []byte("v1"))
+	c.upload(t, key, []byte("v2"))
+
+	versions, err := c.store.ListVersions(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.NoError(t, c.store.RestoreVersion(ctx, key, versions[1].VersionId))
+	c.requireContent(t, key, []byte("v1"))
+
+	// 恢复生成新的当前版本，原当前版本保留
+	versions, err = c.store.ListVersions(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	require.True(t, versions[0].IsLatest)
+}
+
+func (c *conformance) testRestoreCurrentVersion(t *testing.T) {
+	ctx := context.Background()
+	key := c.key("restore-current.txt")
+	c.cleanupVersions(t, key)
+	c.upload(t, key, []byte("v1"),
+		oss.WithContentType("text/csv"),
+		oss.WithMetadata(map[string]string{"owner": "osstest"}),
+	)
+	info, err := c.store.Stat(ctx, key)
+	require.NoError(t, err)
+
+	require.NoError(t, c.store.RestoreVersion(ctx, key, info.VersionId))
+	c.requireContent(t, key, []byte("v1"))
+	restored, err := c.store.Stat(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, "text/csv", restored.ContentType)
+	require.Equal(t, map[string]string{"owner": "osstest"}, restored.Metadata)
+	versions, err := c.store.ListVersions(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.Equal(t, info.VersionId, versions[1].VersionId)
+}
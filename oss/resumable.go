@@ -0,0 +1,184 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// UploadResumable 可续传的分片上传，reader 须支持随机读取，如 *os.File
+// 进度以 key 为 id 保存在 checkpoints 中，每个分片上传成功后更新
+// 再次调用时通过 ListParts 与存储核对已上传的分片，只上传缺少的分片
+// 失败时不终止分片上传以便续传，放弃的上传可由 SweepStaleUploads 清理
+// 文件大小不超过一个分片时直接调用 Oss.Upload，不保存进度
+// 条件上传与 Upload 相同在开始及完成分片上传前检查，完成前条件不满足时终止分片上传并删除进度
+func (u *Uploader) UploadResumable(ctx context.Context, key string, reader io.ReaderAt, size int64, checkpoints CheckpointStore, opts ...UploadOption) error {
+	if u.PartSize < MinPartSize {
+		return fmt.Errorf("oss: part size %d is less than %d", u.PartSize, MinPartSize)
+	}
+	if size <= u.PartSize {
+		// 文件已变小，之前的分片上传不会再完成
+		if err := u.discardCheckpoint(ctx, key, checkpoints); err != nil {
+			return err
+		}
+		return retry(ctx, u.MaxRetries, u.RetryDelay, func() error {
+			return u.store.Upload(ctx, key, io.NewSectionReader(reader, 0, size), opts...)
+		})
+	}
+	partsNum := (size + u.PartSize - 1) / u.PartSize
+	if partsNum > MaxUploadParts {
+		return fmt.Errorf("oss: upload exceeds %d parts, increase the part size", MaxUploadParts)
+	}
+
+	cond := NewUploadOptions(opts...).Precondition
+	if err := u.checkPrecondition(ctx, key, cond); err != nil {
+		return err
+	}
+	checkpoint, err := u.resume(ctx, key, size, partsNum, checkpoints)
+	if err != nil {
+		return err
+	}
+	if checkpoint == nil {
+		uploadId, err := u.store.CreateMultipartUpload(ctx, key, opts...)
+		if err != nil {
+			return err
+		}
+		checkpoint = &Checkpoint{Key: key, UploadId: uploadId, Size: size, PartSize: u.PartSize}
+		if err := checkpoints.Save(ctx, key, checkpoint); err != nil {
+			return err
+		}
+	}
+
+	if err := u.uploadMissingParts(ctx, reader, partsNum, checkpoint, checkpoints); err != nil {
+		return err
+	}
+	if err := u.checkPrecondition(ctx, key, cond); err != nil {
+		if errors.Is(err, ErrPreconditionFailed) {
+			// 条件不会再满足，已上传的分片不可用
+			_ = u.store.AbortMultipartUpload(context.Background(), key, checkpoint.UploadId)
+			_ = checkpoints.Delete(ctx, key)
+		}
+		return err
+	}
+	if _, err := u.store.CompleteMultipartUpload(ctx, key, checkpoint.UploadId, partsNum); err != nil {
+		return err
+	}
+	return checkpoints.Delete(ctx, key)
+}
+
+// resume 读取进度并与存储核对，无法续传时返回 nil
+func (u *Uploader) resume(ctx context.Context, key string, size, partsNum int64, checkpoints CheckpointStore) (*Checkpoint, error) {
+	checkpoint, err := checkpoints.Load(ctx, key)
+	if err != nil || checkpoint == nil {
+		return nil, err
+	}
+
+	if checkpoint.Key == key && checkpoint.Size == size && checkpoint.PartSize == u.PartSize {
+		parts, err := u.store.ListParts(ctx, key, checkpoint.UploadId, partsNum)
+		if err == nil {
+			checkpoint.Parts = parts
+			return checkpoint, nil
+		}
+		if !errors.Is(err, ErrUploadNotFound) {
+			return nil, err
+		}
+	} else {
+		// 文件或分片大小已改变，已上传的分片不可用
+		_ = u.store.AbortMultipartUpload(ctx, checkpoint.Key, checkpoint.UploadId)
+	}
+	return nil, checkpoints.Delete(ctx, key)
+}
+
+// discardCheckpoint 终止进度中记录的分片上传并删除进度，没有进度时不做处理
+func (u *Uploader) discardCheckpoint(ctx context.Context, key string, checkpoints CheckpointStore) error {
+	checkpoint, err := checkpoints.Load(ctx, key)
+	if err != nil || checkpoint == nil {
+		return err
+	}
+	_ = u.store.AbortMultipartUpload(ctx, checkpoint.Key, checkpoint.UploadId)
+	return checkpoints.Delete(ctx, key)
+}
+
+// uploadMissingParts 并发上传 checkpoint 中没有的分片，每个分片成功后保存进度
+func (u *Uploader) uploadMissingParts(ctx context.Context, reader io.ReaderAt, partsNum int64, checkpoint *Checkpoint, checkpoints CheckpointStore) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	uploaded := make(map[int64]bool, len(checkpoint.Parts))
+	for _, part := range checkpoint.Parts {
+		uploaded[part.PartNumber] = true
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	concurrency := u.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	for partNumber := int64(1); partNumber <= partsNum; partNumber++ {
+		if uploaded[partNumber] {
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			mu.Lock()
+			fail(err)
+			mu.Unlock()
+			break
+		}
+
+		wg.Add(1)
+		go func(partNumber int64) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			offset := (partNumber - 1) * u.PartSize
+			length := u.PartSize
+			if offset+length > checkpoint.Size {
+				length = checkpoint.Size - offset
+			}
+
+			var etag string
+			err := retry(ctx, u.MaxRetries, u.RetryDelay, func() (err error) {
+				etag, err = u.store.UploadPart(ctx, checkpoint.Key, checkpoint.UploadId, partNumber, io.NewSectionReader(reader, offset, length))
+				return err
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				fail(fmt.Errorf("upload part %d: %w", partNumber, err))
+				return
+			}
+			checkpoint.Parts = append(checkpoint.Parts, &CompletedPart{PartNumber: partNumber, ETag: etag})
+			sort.Slice(checkpoint.Parts, func(i, j int) bool {
+				return checkpoint.Parts[i].PartNumber < checkpoint.Parts[j].PartNumber
+			})
+			if err := checkpoints.Save(ctx, checkpoint.Key, checkpoint); err != nil {
+				fail(err)
+			}
+		}(partNumber)
+	}
+	wg.Wait()
+
+	return firstErr
+}
@@ -0,0 +1,168 @@
+package oss_test
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/blues120/ias-kit/oss"
+	"github.com/blues120/ias-kit/oss/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failUploadPart 返回 failing 不为 0 时，自第 from 次调用起 UploadPart 返回 oss.ErrPermission 的 Hook
+// calls 记录 UploadPart 的调用次数
+func failUploadPart(calls *int64, failing *int32, from int64) memory.Hook {
+	return func(ctx context.Context, op, key string) error {
+		if op != "UploadPart" {
+			return nil
+		}
+		if n := atomic.AddInt64(calls, 1); n >= from && atomic.LoadInt32(failing) != 0 {
+			return oss.ErrPermission
+		}
+		return nil
+	}
+}
+
+func TestUploader_UploadResumable(t *testing.T) {
+	ctx := context.Background()
+	checkpoints, err := oss.NewFileCheckpointStore(t.TempDir())
+	require.NoError(t, err)
+
+	data := make([]byte, 3*oss.MinPartSize+10)
+	rand.Read(data)
+	// 只有一个并发，第二个分片上传失败
+	var calls int64
+	failing := int32(1)
+	store := memory.NewMemory(memory.WithHook(failUploadPart(&calls, &failing, 2)))
+	uploader := oss.NewUploader(store, func(u *oss.Uploader) {
+		u.PartSize = oss.MinPartSize
+		u.Concurrency = 1
+		u.RetryDelay = 0
+	})
+
+	err = uploader.UploadResumable(ctx, "key", bytes.NewReader(data), int64(len(data)), checkpoints)
+	require.ErrorIs(t, err, oss.ErrPermission)
+	require.Equal(t, int64(2), atomic.LoadInt64(&calls))
+
+	checkpoint, err := checkpoints.Load(ctx, "key")
+	require.NoError(t, err)
+	require.NotNil(t, checkpoint)
+	require.Len(t, checkpoint.Parts, 1)
+
+	// 进度中缺少的分片以 ListParts 为准，不重复上传
+	checkpoint.Parts = nil
+	require.NoError(t, checkpoints.Save(ctx, "key", checkpoint))
+
+	atomic.StoreInt32(&failing, 0)
+	atomic.StoreInt64(&calls, 0)
+	require.NoError(t, uploader.UploadResumable(ctx, "key", bytes.NewReader(data), int64(len(data)), checkpoints))
+	require.Equal(t, int64(3), atomic.LoadInt64(&calls))
+	requireContent(t, store, "key", data)
+
+	checkpoint, err = checkpoints.Load(ctx, "key")
+	require.NoError(t, err)
+	require.Nil(t, checkpoint)
+	uploads, err := store.ListMultipartUploads(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, uploads)
+}
+
+func TestUploader_UploadResumableChanged(t *testing.T) {
+	ctx := context.Background()
+	checkpoints, err := oss.NewFileCheckpointStore(t.TempDir())
+	require.NoError(t, err)
+
+	data := make([]byte, 2*oss.MinPartSize+10)
+	rand.Read(data)
+	var calls int64
+	failing := int32(1)
+	store := memory.NewMemory(memory.WithHook(failUploadPart(&calls, &failing, 2)))
+	uploader := oss.NewUploader(store, func(u *oss.Uploader) {
+		u.PartSize = oss.MinPartSize
+		u.RetryDelay = 0
+	})
+	require.Error(t, uploader.UploadResumable(ctx, "key", bytes.NewReader(data), int64(len(data)), checkpoints))
+
+	// 文件大小改变时终止原来的分片上传并重新上传
+	data = data[:2*oss.MinPartSize]
+	atomic.StoreInt32(&failing, 0)
+	atomic.StoreInt64(&calls, 0)
+	require.NoError(t, uploader.UploadResumable(ctx, "key", bytes.NewReader(data), int64(len(data)), checkpoints))
+	require.Equal(t, int64(2), atomic.LoadInt64(&calls))
+	requireContent(t, store, "key", data)
+
+	uploads, err := store.ListMultipartUploads(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, uploads)
+
+	// 不超过一个分片时直接上传
+	require.NoError(t, uploader.UploadResumable(ctx, "small", bytes.NewReader(data[:10]), 10, checkpoints))
+	requireContent(t, store, "small", data[:10])
+}
+
+func TestUploader_UploadResumableShrunk(t *testing.T) {
+	ctx := context.Background()
+	checkpoints, err := oss.NewFileCheckpointStore(t.TempDir())
+	require.NoError(t, err)
+
+	data := make([]byte, 2*oss.MinPartSize+10)
+	rand.Read(data)
+	var calls int64
+	failing := int32(1)
+	store := memory.NewMemory(memory.WithHook(failUploadPart(&calls, &failing, 2)))
+	uploader := oss.NewUploader(store, func(u *oss.Uploader) {
+		u.PartSize = oss.MinPartSize
+		u.RetryDelay = 0
+	})
+	require.Error(t, uploader.UploadResumable(ctx, "key", bytes.NewReader(data), int64(len(data)), checkpoints))
+	checkpoint, err := checkpoints.Load(ctx, "key")
+	require.NoError(t, err)
+	require.NotNil(t, checkpoint)
+
+	// 文件变为不超过一个分片时直接上传，终止原来的分片上传并删除进度
+	require.NoError(t, uploader.UploadResumable(ctx, "key", bytes.NewReader(data[:10]), 10, checkpoints))
+	requireContent(t, store, "key", data[:10])
+	checkpoint, err = checkpoints.Load(ctx, "key")
+	require.NoError(t, err)
+	require.Nil(t, checkpoint)
+	uploads, err := store.ListMultipartUploads(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, uploads)
+}
+
+func TestUploader_UploadResumablePrecondition(t *testing.T) {
+	ctx := context.Background()
+	checkpoints, err := oss.NewFileCheckpointStore(t.TempDir())
+	require.NoError(t, err)
+	data := make([]byte, 2*oss.MinPartSize+10)
+	rand.Read(data)
+
+	// 上传分片期间文件被创建，完成分片上传前检查条件，终止分片上传并删除进度
+	var (
+		store oss.Oss
+		once  sync.Once
+	)
+	store = memory.NewMemory(memory.WithHook(func(ctx context.Context, op, key string) error {
+		if op == "UploadPart" {
+			once.Do(func() {
+				assert.NoError(t, store.Upload(ctx, key, bytes.NewReader([]byte("1"))))
+			})
+		}
+		return nil
+	}))
+	err = newUploader(store).UploadResumable(ctx, "key", bytes.NewReader(data), int64(len(data)), checkpoints, oss.WithIfNotExists())
+	require.ErrorIs(t, err, oss.ErrPreconditionFailed)
+	requireContent(t, store, "key", []byte("1"))
+
+	checkpoint, err := checkpoints.Load(ctx, "key")
+	require.NoError(t, err)
+	require.Nil(t, checkpoint)
+	uploads, err := store.ListMultipartUploads(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, uploads)
+}
@@ -0,0 +1,48 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// permanentErrors 重试也不会成功的错误
+var permanentErrors = []error{
+	context.Canceled,
+	context.DeadlineExceeded,
+	ErrNotFound,
+	ErrPermission,
+	ErrPreconditionFailed,
+	ErrInvalidKey,
+	ErrInvalidRange,
+	ErrInvalidTags,
+	ErrNotSupported,
+	ErrUploadNotFound,
+}
+
+func isRetryable(err error) bool {
+	for _, target := range permanentErrors {
+		if errors.Is(err, target) {
+			return false
+		}
+	}
+	return true
+}
+
+// retry 执行 fn，失败时最多重试 maxRetries 次，第 n 次重试前等待 delay * 2^(n-1)
+func retry(ctx context.Context, maxRetries int, delay time.Duration, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= maxRetries || !isRetryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(delay << attempt)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package s3
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/blues120/ias-kit/oss"
+)
+
+// errorKinds s3 错误码对应的错误类型
+var errorKinds = map[string]error{
+	s3.ErrCodeNoSuchKey:    oss.ErrNotFound,
+	"NoSuchVersion":        oss.ErrNotFound,
+	"NotFound":             oss.ErrNotFound, // HEAD 请求没有响应体，只有状态码
+	s3.ErrCodeNoSuchUpload: oss.ErrUploadNotFound,
+	"AccessDenied":         oss.ErrPermission,
+	"Forbidden":            oss.ErrPermission,
+	"PreconditionFailed":   oss.ErrPreconditionFailed,
+	"NotModified":          oss.ErrPreconditionFailed, // GET 请求 If-None-Match 条件不满足时返回 304
+	"InvalidRange":         oss.ErrInvalidRange,
+}
+
+// statusKinds 错误码无法识别时按 http 状态码判断
+var statusKinds = map[int]error{
+	http.StatusNotFound:           oss.ErrNotFound,
+	http.StatusForbidden:          oss.ErrPermission,
+	http.StatusPreconditionFailed: oss.ErrPreconditionFailed,
+	http.StatusNotModified:        oss.ErrPreconditionFailed,
+}
+
+// convertError 将 sdk 返回的错误转为 oss 包定义的错误类型，原始错误可通过 errors.As 获取
+// s3manager 会将请求的错误包装在 OrigErr 中，需逐层查找
+func convertError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var ossErr *oss.Error
+	if errors.As(err, &ossErr) {
+		return err
+	}
+
+	for e := err; e != nil; {
+		awsErr, ok := e.(awserr.Error)
+		if !ok {
+			break
+		}
+		if kind, ok := errorKinds[awsErr.Code()]; ok {
+			return &oss.Error{Kind: kind, Err: err}
+		}
+		if reqErr, ok := e.(awserr.RequestFailure); ok {
+			if kind, ok := statusKinds[reqErr.StatusCode()]; ok {
+				return &oss.Error{Kind: kind, Err: err}
+			}
+		}
+		e = awsErr.OrigErr()
+	}
+	return err
+}
+
+// wrapError 供方法返回前转换错误，用法为 defer wrapError(&err)
+func wrapError(err *error) {
+	*err = convertError(*err)
+}
+
+// isErrorCode 判断 sdk 返回的错误码
+func isErrorCode(err error, code string) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == code
+}
@@ -0,0 +1,29 @@
+package s3
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/blues120/ias-kit/oss"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertError(t *testing.T) {
+	notFound := awserr.NewRequestFailure(awserr.New("NotFound", "Not Found", nil), http.StatusNotFound, "")
+	forbidden := awserr.NewRequestFailure(awserr.New("SomethingElse", "", nil), http.StatusForbidden, "")
+	// s3manager 将请求的错误包装在 OrigErr 中
+	multipart := awserr.New("MultipartUpload", "upload multipart failed", awserr.New("NoSuchUpload", "", nil))
+	other := errors.New("other")
+
+	require.ErrorIs(t, convertError(notFound), oss.ErrNotFound)
+	require.ErrorIs(t, convertError(forbidden), oss.ErrPermission)
+	require.ErrorIs(t, convertError(multipart), oss.ErrUploadNotFound)
+	require.Equal(t, other, convertError(other))
+	require.NoError(t, convertError(nil))
+
+	var reqErr awserr.RequestFailure
+	require.ErrorAs(t, convertError(notFound), &reqErr)
+	require.Equal(t, http.StatusNotFound, reqErr.StatusCode())
+}
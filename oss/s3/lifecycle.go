@@ -0,0 +1,78 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/blues120/ias-kit/oss"
+)
+
+// ExpirationRule 按前缀自动删除文件的生命周期规则，对应 local 的 oss.WithTTL
+type ExpirationRule struct {
+	// ID 规则 id，为空时使用 "oss-expire-" + Prefix
+	ID string
+
+	// Prefix 规则生效的前缀，为空时对整个 bucket 生效
+	Prefix string
+
+	// Days 文件上传后的天数，s3 生命周期规则的最小单位为天
+	Days int64
+}
+
+// PutExpirationRules 在 bucket 上配置按前缀过期删除的生命周期规则
+// bucket 上其他的生命周期规则保持不变，id 相同的规则会被替换
+// s3 每天执行一次生命周期规则，文件不会在过期后立即删除
+func PutExpirationRules(ctx context.Context, store oss.Oss, rules []ExpirationRule) error {
+	r, ok := store.(*awsS3)
+	if !ok {
+		return fmt.Errorf("the store is not a s3 store")
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	newRules := make([]*s3.LifecycleRule, 0, len(rules))
+	ids := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if rule.Days < 1 {
+			return fmt.Errorf("the expiration days of prefix %q must be at least 1", rule.Prefix)
+		}
+		if err := checkPrefix(rule.Prefix); err != nil {
+			return err
+		}
+		id := rule.ID
+		if id == "" {
+			id = "oss-expire-" + rule.Prefix
+		}
+		ids[id] = true
+		newRules = append(newRules, &s3.LifecycleRule{
+			ID:         aws.String(id),
+			Status:     aws.String(s3.ExpirationStatusEnabled),
+			Filter:     &s3.LifecycleRuleFilter{Prefix: aws.String(rule.Prefix)},
+			Expiration: &s3.LifecycleExpiration{Days: aws.Int64(rule.Days)},
+		})
+	}
+
+	// 保留 bucket 上已有的其他规则，bucket 没有生命周期配置时返回 NoSuchLifecycleConfiguration
+	existing, err := r.core.GetBucketLifecycleConfigurationWithContext(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(r.bucket),
+	})
+	if err != nil && !isErrorCode(err, "NoSuchLifecycleConfiguration") {
+		return convertError(err)
+	}
+	if existing != nil {
+		for _, rule := range existing.Rules {
+			if !ids[aws.StringValue(rule.ID)] {
+				newRules = append(newRules, rule)
+			}
+		}
+	}
+
+	_, err = r.core.PutBucketLifecycleConfigurationWithContext(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(r.bucket),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: newRules},
+	})
+	return convertError(err)
+}
@@ -0,0 +1,15 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blues120/ias-kit/oss"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckUploadOptions(t *testing.T) {
+	require.NoError(t, checkUploadOptions(oss.NewUploadOptions(oss.WithTags(map[string]string{"k": "v"}))))
+	require.ErrorIs(t, checkUploadOptions(oss.NewUploadOptions(oss.WithTTL(time.Hour))), oss.ErrNotSupported)
+	require.ErrorIs(t, checkUploadOptions(oss.NewUploadOptions(oss.WithTags(map[string]string{"": "v"}))), oss.ErrInvalidTags)
+}
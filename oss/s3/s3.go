@@ -1,64 +1,174 @@
 package s3
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/blues120/ias-kit/oss"
 )
 
 const (
-	AclPrivate         = "private"
-	AclPublicRead      = "public-read"
-	AclPublicReadWrite = "public-read-write"
+	AclPrivate         = oss.AclPrivate
+	AclPublicRead      = oss.AclPublicRead
+	AclPublicReadWrite = oss.AclPublicReadWrite
 )
 
 type awsS3 struct {
-	bucket string
-	core   *s3.S3
+	bucket   string
+	core     *s3.S3
+	uploader *s3manager.Uploader
 
 	endpointAlias string
+
+	partSize    int64
+	concurrency int
+}
+
+type Option func(*awsS3)
+
+// WithPartSize 设置 Upload 自动分片时每个分片的大小，最小 5MB，默认 5MB
+func WithPartSize(partSize int64) Option {
+	return func(r *awsS3) {
+		r.partSize = partSize
+	}
 }
 
-func NewS3(bucket string, cfg *aws.Config, endpointAlias string) (oss.Oss, error) {
+// WithConcurrency 设置 Upload 自动分片时并发上传的分片数，默认 5
+func WithConcurrency(concurrency int) Option {
+	return func(r *awsS3) {
+		r.concurrency = concurrency
+	}
+}
+
+func NewS3(bucket string, cfg *aws.Config, endpointAlias string, opts ...Option) (oss.Oss, error) {
 	se, err := session.NewSession(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	return &awsS3{
+	r := &awsS3{
 		core:          s3.New(se, cfg),
 		bucket:        bucket,
 		endpointAlias: endpointAlias,
-	}, nil
+		partSize:      s3manager.DefaultUploadPartSize,
+		concurrency:   s3manager.DefaultUploadConcurrency,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.partSize < s3manager.MinUploadPartSize {
+		return nil, fmt.Errorf("the part size must be at least %d bytes", s3manager.MinUploadPartSize)
+	}
+	if r.concurrency < 1 {
+		return nil, fmt.Errorf("the concurrency must be at least 1")
+	}
+
+	r.uploader = s3manager.NewUploaderWithClient(r.core, func(u *s3manager.Uploader) {
+		u.PartSize = r.partSize
+		u.Concurrency = r.concurrency
+	})
+	return r, nil
 }
 
-func (r *awsS3) Upload(ctx context.Context, key string, reader io.Reader) error {
-	fileBytes, err := io.ReadAll(reader)
+// Upload 流式上传，内容小于一个分片时使用单次 PUT，否则自动转为分片上传
+// 内存占用最多为 partSize * (concurrency + 1)，与文件大小无关
+func (r *awsS3) Upload(ctx context.Context, key string, reader io.Reader, opts ...oss.UploadOption) (err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
 	if err != nil {
 		return err
 	}
-	obj := &s3.PutObjectInput{
-		Body:   bytes.NewReader(fileBytes),
-		Bucket: aws.String(r.bucket),
-		Key:    aws.String(key),
+	o := oss.NewUploadOptions(opts...)
+	if err := checkUploadOptions(o); err != nil {
+		return err
+	}
+	obj := &s3manager.UploadInput{
+		Body:               reader,
+		Bucket:             aws.String(r.bucket),
+		Key:                aws.String(key),
+		ContentType:        optionalString(o.ContentType),
+		ContentDisposition: optionalString(o.ContentDisposition),
+		CacheControl:       optionalString(o.CacheControl),
+		ContentEncoding:    optionalString(o.ContentEncoding),
+		ACL:                optionalString(o.ACL),
+		Metadata:           aws.StringMap(o.Metadata),
+		Tagging:            optionalString(oss.EncodeTags(o.Tags)),
 	}
-	_, err = r.core.PutObjectWithContext(ctx, obj)
+	_, err = r.uploader.UploadWithContext(ctx, obj, s3manager.WithUploaderRequestOptions(preconditionHeaders(o.Precondition)))
 	return err
 }
 
-func (r *awsS3) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+// checkUploadOptions 检查上传参数
+// s3 不支持为单个文件设置过期时间，需通过 PutExpirationRules 按前缀设置
+func checkUploadOptions(o *oss.UploadOptions) error {
+	if !o.Expires.IsZero() {
+		return fmt.Errorf("%w: expires, use PutExpirationRules instead", oss.ErrNotSupported)
+	}
+	return oss.ValidateTags(o.Tags)
+}
+
+// preconditionHeaders 设置条件上传的请求头
+// sdk 的 PutObjectInput 不支持 If-Match、If-None-Match，只能直接设置请求头；
+// 自动分片时条件在 CompleteMultipartUpload 时检查
+func preconditionHeaders(cond oss.Precondition) request.Option {
+	return func(req *request.Request) {
+		switch req.Operation.Name {
+		case "PutObject", "CompleteMultipartUpload":
+		default:
+			return
+		}
+		if cond.IfMatch != "" {
+			req.HTTPRequest.Header.Set("If-Match", cond.IfMatch)
+		}
+		if cond.IfNoneMatch != "" {
+			req.HTTPRequest.Header.Set("If-None-Match", cond.IfNoneMatch)
+		}
+	}
+}
+
+// checkPrefix 校验列举、删除的前缀，与 key 相同不做规范化，规则见 oss.ValidateKey
+func checkPrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	return oss.ValidateKey(prefix)
+}
+
+// optionalString 空字符串表示未设置，不发送对应的头
+func optionalString(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return aws.String(v)
+}
+
+func (r *awsS3) Download(ctx context.Context, key string, opts ...oss.DownloadOption) (_ io.ReadCloser, err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	o := oss.NewDownloadOptions(opts...)
 	obj := &s3.GetObjectInput{
-		Bucket: aws.String(r.bucket),
-		Key:    aws.String(key),
+		Bucket:      aws.String(r.bucket),
+		Key:         aws.String(key),
+		IfMatch:     optionalString(o.IfMatch),
+		IfNoneMatch: optionalString(o.IfNoneMatch),
 	}
 	out, err := r.core.GetObjectWithContext(ctx, obj)
 	if err != nil {
@@ -67,36 +177,597 @@ func (r *awsS3) Download(ctx context.Context, key string) (io.ReadCloser, error)
 	return out.Body, nil
 }
 
-func (r *awsS3) Delete(ctx context.Context, key string) error {
+func (r *awsS3) DownloadRange(ctx context.Context, key string, offset, length int64) (_ io.ReadCloser, err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 {
+		return nil, oss.ErrInvalidRange
+	}
+	// length 超出文件大小时与 local 相同读取到文件末尾，过大时使用不带结束位置的范围，避免溢出
+	rng := fmt.Sprintf("bytes=%d-", offset)
+	if length > 0 && length <= math.MaxInt64-offset {
+		rng += strconv.FormatInt(offset+length-1, 10)
+	}
+
+	out, err := r.core.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(rng),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (r *awsS3) Delete(ctx context.Context, key string) (err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return err
+	}
 	obj := &s3.DeleteObjectInput{
 		Bucket: aws.String(r.bucket),
 		Key:    aws.String(key),
 	}
-	_, err := r.core.DeleteObjectWithContext(ctx, obj)
+	_, err = r.core.DeleteObjectWithContext(ctx, obj)
 	return err
 }
 
-func (r *awsS3) Exists(ctx context.Context, key string) (bool, error) {
+// maxDeleteObjects DeleteObjects 单次最多删除的文件数
+const maxDeleteObjects = 1000
+
+// DeleteMany 使用 DeleteObjects 每 1000 个一批删除
+func (r *awsS3) DeleteMany(ctx context.Context, keys []string) (_ *oss.DeleteResult, err error) {
+	defer wrapError(&err)
+	ret := &oss.DeleteResult{
+		Deleted: make([]string, 0, len(keys)),
+		Errors:  make([]*oss.DeleteError, 0),
+	}
+
+	valid := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if err := oss.ValidateKey(key); err != nil {
+			ret.Errors = append(ret.Errors, &oss.DeleteError{Key: key, Err: err})
+			continue
+		}
+		valid = append(valid, key)
+	}
+
+	for start := 0; start < len(valid); start += maxDeleteObjects {
+		end := start + maxDeleteObjects
+		if end > len(valid) {
+			end = len(valid)
+		}
+
+		objects := make([]*s3.ObjectIdentifier, 0, end-start)
+		for _, key := range valid[start:end] {
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(key)})
+		}
+		resp, err := r.core.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(r.bucket),
+			Delete: &s3.Delete{Objects: objects},
+		})
+		if err != nil {
+			return ret, err
+		}
+
+		for _, deleted := range resp.Deleted {
+			ret.Deleted = append(ret.Deleted, aws.StringValue(deleted.Key))
+		}
+		for _, e := range resp.Errors {
+			ret.Errors = append(ret.Errors, &oss.DeleteError{
+				Key: aws.StringValue(e.Key),
+				Err: convertError(awserr.New(aws.StringValue(e.Code), aws.StringValue(e.Message), nil)),
+			})
+		}
+	}
+	return ret, nil
+}
+
+// DeletePrefix 分页列举后批量删除
+func (r *awsS3) DeletePrefix(ctx context.Context, prefix string) (_ *oss.DeleteResult, err error) {
+	defer wrapError(&err)
+	if prefix == "" {
+		return nil, oss.ErrEmptyPrefix
+	}
+	if err := checkPrefix(prefix); err != nil {
+		return nil, err
+	}
+
+	ret := &oss.DeleteResult{
+		Deleted: make([]string, 0),
+		Errors:  make([]*oss.DeleteError, 0),
+	}
+	opts := &oss.ListOptions{MaxKeys: maxDeleteObjects}
+	for {
+		page, err := r.List(ctx, prefix, opts)
+		if err != nil {
+			return ret, err
+		}
+
+		keys := make([]string, 0, len(page.Objects))
+		for _, obj := range page.Objects {
+			keys = append(keys, obj.Key)
+		}
+		deleted, err := r.DeleteMany(ctx, keys)
+		if deleted != nil {
+			ret.Deleted = append(ret.Deleted, deleted.Deleted...)
+			ret.Errors = append(ret.Errors, deleted.Errors...)
+		}
+		if err != nil {
+			return ret, err
+		}
+
+		if !page.IsTruncated {
+			return ret, nil
+		}
+		opts.ContinuationToken = page.NextContinuationToken
+	}
+}
+
+// checkKeys 校验复制、移动的源及目标 key
+func checkKeys(srcKey, dstKey string) error {
+	if err := oss.ValidateKey(srcKey); err != nil {
+		return err
+	}
+	return oss.ValidateKey(dstKey)
+}
+
+const (
+	// maxCopyObjectSize CopyObject 支持的最大文件，超过时使用分片复制
+	maxCopyObjectSize = 5 * 1024 * 1024 * 1024
+
+	// copyPartSize 分片复制时每个分片的大小
+	copyPartSize = 512 * 1024 * 1024
+)
+
+// Copy 小于 5GB 的文件使用 CopyObject，否则使用 UploadPartCopy 分片复制
+func (r *awsS3) Copy(ctx context.Context, srcKey, dstKey string) (err error) {
+	defer wrapError(&err)
+	err = checkKeys(srcKey, dstKey)
+	if err != nil {
+		return err
+	}
+	if srcKey == dstKey {
+		_, err := r.Stat(ctx, srcKey)
+		return err
+	}
+	return r.copyObject(ctx, srcKey, "", dstKey)
+}
+
+// copyObject 复制文件的指定版本，versionId 为空时复制当前版本
+func (r *awsS3) copyObject(ctx context.Context, srcKey, versionId, dstKey string) error {
+	head, err := r.core.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket:    aws.String(r.bucket),
+		Key:       aws.String(srcKey),
+		VersionId: optionalString(versionId),
+	})
+	if err != nil {
+		return err
+	}
+
+	copySource := url.PathEscape(r.bucket + "/" + srcKey)
+	if versionId != "" {
+		copySource += "?versionId=" + url.QueryEscape(versionId)
+	}
+	if aws.Int64Value(head.ContentLength) <= maxCopyObjectSize {
+		input := &s3.CopyObjectInput{
+			Bucket:     aws.String(r.bucket),
+			Key:        aws.String(dstKey),
+			CopySource: aws.String(copySource),
+		}
+		// s3 拒绝不修改元数据的复制到自身，如 RestoreVersion 当前版本，以源版本的元数据替换
+		if srcKey == dstKey {
+			input.MetadataDirective = aws.String(s3.MetadataDirectiveReplace)
+			input.ContentType = head.ContentType
+			input.ContentDisposition = head.ContentDisposition
+			input.CacheControl = head.CacheControl
+			input.ContentEncoding = head.ContentEncoding
+			input.Metadata = head.Metadata
+		}
+		_, err = r.core.CopyObjectWithContext(ctx, input)
+		return err
+	}
+
+	// CopyObject 默认复制标签，分片复制时需在创建分片上传时指定
+	tagging, err := r.core.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
+		Bucket:    aws.String(r.bucket),
+		Key:       aws.String(srcKey),
+		VersionId: optionalString(versionId),
+	})
+	if err != nil {
+		return err
+	}
+	return r.multipartCopy(ctx, head, transformTags(tagging.TagSet), copySource, dstKey)
+}
+
+// multipartCopy 分片复制大文件，复制失败时终止分片上传
+func (r *awsS3) multipartCopy(ctx context.Context, head *s3.HeadObjectOutput, tags map[string]string, copySource, dstKey string) error {
+	resp, err := r.core.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(r.bucket),
+		Key:                aws.String(dstKey),
+		ContentType:        head.ContentType,
+		ContentDisposition: head.ContentDisposition,
+		CacheControl:       head.CacheControl,
+		ContentEncoding:    head.ContentEncoding,
+		Metadata:           head.Metadata,
+		Tagging:            optionalString(oss.EncodeTags(tags)),
+	})
+	if err != nil {
+		return err
+	}
+	uploadId := resp.UploadId
+
+	size := aws.Int64Value(head.ContentLength)
+	partsNum := (size + copyPartSize - 1) / copyPartSize
+	parts := make([]*s3.CompletedPart, partsNum)
+
+	// 任一分片复制失败后取消其余分片，不再启动新的分片
+	partCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		sem      = make(chan struct{}, r.concurrency)
+	)
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+		mu.Unlock()
+	}
+	for i := int64(0); i < partsNum; i++ {
+		start := i * copyPartSize
+		end := start + copyPartSize - 1
+		if end >= size {
+			end = size - 1
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-partCtx.Done():
+		}
+		if partCtx.Err() != nil {
+			fail(partCtx.Err())
+			break
+		}
+
+		wg.Add(1)
+		go func(i, start, end int64) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			out, err := r.core.UploadPartCopyWithContext(partCtx, &s3.UploadPartCopyInput{
+				Bucket:          aws.String(r.bucket),
+				Key:             aws.String(dstKey),
+				UploadId:        uploadId,
+				PartNumber:      aws.Int64(i + 1),
+				CopySource:      aws.String(copySource),
+				CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+			})
+			if err != nil {
+				fail(err)
+				return
+			}
+			parts[i] = &s3.CompletedPart{
+				PartNumber: aws.Int64(i + 1),
+				ETag:       out.CopyPartResult.ETag,
+			}
+		}(i, start, end)
+	}
+	wg.Wait()
+
+	if firstErr == nil {
+		_, firstErr = r.core.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(r.bucket),
+			Key:             aws.String(dstKey),
+			UploadId:        uploadId,
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+		})
+	}
+	if firstErr != nil {
+		_, _ = r.core.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(r.bucket),
+			Key:      aws.String(dstKey),
+			UploadId: uploadId,
+		})
+		return firstErr
+	}
+	return nil
+}
+
+// Move 复制后删除源文件
+func (r *awsS3) Move(ctx context.Context, srcKey, dstKey string) (err error) {
+	defer wrapError(&err)
+	err = checkKeys(srcKey, dstKey)
+	if err != nil {
+		return err
+	}
+	if srcKey == dstKey {
+		_, err := r.Stat(ctx, srcKey)
+		return err
+	}
+	if err := r.Copy(ctx, srcKey, dstKey); err != nil {
+		return err
+	}
+	return r.Delete(ctx, srcKey)
+}
+
+func (r *awsS3) Exists(ctx context.Context, key string) (_ bool, err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return false, err
+	}
 	obj := &s3.HeadObjectInput{
 		Bucket: aws.String(r.bucket),
 		Key:    aws.String(key),
 	}
-	_, err := r.core.HeadObject(obj)
+	_, err = r.core.HeadObject(obj)
 	if err != nil {
-		if awsErr, ok := err.(awserr.Error); ok {
-			switch awsErr.Code() {
-			case "NotFound":
-				return false, nil
-			default:
-				return false, err
-			}
+		if errors.Is(convertError(err), oss.ErrNotFound) {
+			return false, nil
 		}
 		return false, err
 	}
 	return true, nil
 }
 
+func (r *awsS3) Stat(ctx context.Context, key string) (_ *oss.ObjectInfo, err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.core.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &oss.ObjectInfo{
+		Key:                key,
+		Size:               aws.Int64Value(resp.ContentLength),
+		ETag:               aws.StringValue(resp.ETag),
+		LastModified:       aws.TimeValue(resp.LastModified),
+		ContentType:        aws.StringValue(resp.ContentType),
+		ContentDisposition: aws.StringValue(resp.ContentDisposition),
+		CacheControl:       aws.StringValue(resp.CacheControl),
+		ContentEncoding:    aws.StringValue(resp.ContentEncoding),
+		Metadata:           transformMetadata(resp.Metadata),
+		VersionId:          aws.StringValue(resp.VersionId),
+	}, nil
+}
+
+// transformMetadata sdk 返回的元数据 key 为 http 头格式，统一转为小写
+func transformMetadata(metadata map[string]*string) map[string]string {
+	ret := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		ret[strings.ToLower(k)] = aws.StringValue(v)
+	}
+	return ret
+}
+
+// ListVersions 使用 ListObjectVersions 按前缀列举后过滤出 key 的版本
+func (r *awsS3) ListVersions(ctx context.Context, key string) (_ []*oss.ObjectVersion, err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*oss.ObjectVersion, 0)
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(r.bucket),
+		Prefix: aws.String(key),
+	}
+	err = r.core.ListObjectVersionsPagesWithContext(ctx, input, func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		for _, v := range page.Versions {
+			if aws.StringValue(v.Key) != key {
+				continue
+			}
+			ret = append(ret, &oss.ObjectVersion{
+				Key:          key,
+				VersionId:    aws.StringValue(v.VersionId),
+				IsLatest:     aws.BoolValue(v.IsLatest),
+				Size:         aws.Int64Value(v.Size),
+				ETag:         aws.StringValue(v.ETag),
+				LastModified: aws.TimeValue(v.LastModified),
+			})
+		}
+		for _, m := range page.DeleteMarkers {
+			if aws.StringValue(m.Key) != key {
+				continue
+			}
+			ret = append(ret, &oss.ObjectVersion{
+				Key:            key,
+				VersionId:      aws.StringValue(m.VersionId),
+				IsLatest:       aws.BoolValue(m.IsLatest),
+				IsDeleteMarker: true,
+				LastModified:   aws.TimeValue(m.LastModified),
+			})
+		}
+		// 按 key 排序返回，本页最后的 key 已在 key 之后时，之后的页都是以 key 为前缀的其他 key
+		return aws.StringValue(page.NextKeyMarker) == key
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 版本及删除标记分开返回，合并后按时间从新到旧排列
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].IsLatest != ret[j].IsLatest {
+			return ret[i].IsLatest
+		}
+		return ret[i].LastModified.After(ret[j].LastModified)
+	})
+	return ret, nil
+}
+
+func (r *awsS3) DownloadVersion(ctx context.Context, key, versionId string) (_ io.ReadCloser, err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	out, err := r.core.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket:    aws.String(r.bucket),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (r *awsS3) DeleteVersion(ctx context.Context, key, versionId string) (err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return err
+	}
+	_, err = r.core.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket:    aws.String(r.bucket),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionId),
+	})
+	return err
+}
+
+// RestoreVersion 将指定版本复制到同一 key，生成新的当前版本
+func (r *awsS3) RestoreVersion(ctx context.Context, key, versionId string) (err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return err
+	}
+	return r.copyObject(ctx, key, versionId, key)
+}
+
+func (r *awsS3) GetTags(ctx context.Context, key string) (_ map[string]string, err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.core.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transformTags(resp.TagSet), nil
+}
+
+func transformTags(tagSet []*s3.Tag) map[string]string {
+	tags := make(map[string]string, len(tagSet))
+	for _, tag := range tagSet {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags
+}
+
+// SetTags tags 为空时使用 DeleteObjectTagging
+func (r *awsS3) SetTags(ctx context.Context, key string, tags map[string]string) (err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := oss.ValidateTags(tags); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		_, err = r.core.DeleteObjectTaggingWithContext(ctx, &s3.DeleteObjectTaggingInput{
+			Bucket: aws.String(r.bucket),
+			Key:    aws.String(key),
+		})
+		return err
+	}
+
+	tagSet := make([]*s3.Tag, 0, len(tags))
+	for k, v := range tags {
+		tagSet = append(tagSet, &s3.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	_, err = r.core.PutObjectTaggingWithContext(ctx, &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(r.bucket),
+		Key:     aws.String(key),
+		Tagging: &s3.Tagging{TagSet: tagSet},
+	})
+	return err
+}
+
+func (r *awsS3) List(ctx context.Context, prefix string, opts *oss.ListOptions) (_ *oss.ListResult, err error) {
+	defer wrapError(&err)
+	if err := checkPrefix(prefix); err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &oss.ListOptions{}
+	}
+	maxKeys := opts.MaxKeys
+	if maxKeys == 0 {
+		maxKeys = 1000
+	}
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(r.bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(maxKeys),
+	}
+	if opts.Delimiter != "" {
+		input.Delimiter = aws.String(opts.Delimiter)
+	}
+	if opts.ContinuationToken != "" {
+		input.ContinuationToken = aws.String(opts.ContinuationToken)
+	}
+
+	resp, err := r.core.ListObjectsV2WithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &oss.ListResult{
+		Objects:        make([]*oss.ObjectInfo, 0, len(resp.Contents)),
+		CommonPrefixes: make([]string, 0, len(resp.CommonPrefixes)),
+		IsTruncated:    aws.BoolValue(resp.IsTruncated),
+	}
+	for _, obj := range resp.Contents {
+		ret.Objects = append(ret.Objects, &oss.ObjectInfo{
+			Key:          aws.StringValue(obj.Key),
+			Size:         aws.Int64Value(obj.Size),
+			ETag:         aws.StringValue(obj.ETag),
+			LastModified: aws.TimeValue(obj.LastModified),
+		})
+	}
+	for _, p := range resp.CommonPrefixes {
+		ret.CommonPrefixes = append(ret.CommonPrefixes, aws.StringValue(p.Prefix))
+	}
+	if ret.IsTruncated {
+		ret.NextContinuationToken = aws.StringValue(resp.NextContinuationToken)
+	}
+	return ret, nil
+}
+
 func (r *awsS3) GenerateUrl(ctx context.Context, key string, expire time.Duration) (string, error) {
+	err := oss.ValidateKey(key)
+	if err != nil {
+		return "", err
+	}
 	obj := &s3.GetObjectInput{
 		Bucket: aws.String(r.bucket),
 		Key:    aws.String(key),
@@ -110,9 +781,13 @@ func (r *awsS3) GenerateUrl(ctx context.Context, key string, expire time.Duratio
 }
 
 func (r *awsS3) GenerateTemporaryUrl(ctx context.Context, key string, expire time.Duration) (string, error) {
+	err := oss.ValidateKey(key)
+	if err != nil {
+		return "", err
+	}
 	// 过期时间最长7天
-	if expire > time.Hour*24*7 {
-		return "", fmt.Errorf("the expiration time is up to 7 days")
+	if expire > oss.MaxUrlExpire {
+		return "", oss.ErrExpireTooLong
 	}
 	obj := &s3.GetObjectInput{
 		Bucket: aws.String(r.bucket),
@@ -126,8 +801,13 @@ func (r *awsS3) GenerateTemporaryUrl(ctx context.Context, key string, expire tim
 	return r.genUrl(url), nil
 }
 
-func (r *awsS3) GeneratePermanentUrl(ctx context.Context, key string) (string, error) {
-	_, err := r.core.PutObjectAclWithContext(ctx, &s3.PutObjectAclInput{
+func (r *awsS3) GeneratePermanentUrl(ctx context.Context, key string) (_ string, err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return "", err
+	}
+	_, err = r.core.PutObjectAclWithContext(ctx, &s3.PutObjectAclInput{
 		Bucket: aws.String(r.bucket),
 		Key:    aws.String(key),
 		ACL:    aws.String(AclPublicRead),
@@ -140,6 +820,57 @@ func (r *awsS3) GeneratePermanentUrl(ctx context.Context, key string) (string, e
 	return r.genUrl(url), nil
 }
 
+func (r *awsS3) GenerateUploadUrl(ctx context.Context, key string, expire time.Duration, opts ...oss.UploadOption) (string, error) {
+	err := oss.ValidateKey(key)
+	if err != nil {
+		return "", err
+	}
+	if expire > oss.MaxUrlExpire {
+		return "", oss.ErrExpireTooLong
+	}
+	o := oss.NewUploadOptions(opts...)
+	if err := checkUploadOptions(o); err != nil {
+		return "", err
+	}
+	req, _ := r.core.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:             aws.String(r.bucket),
+		Key:                aws.String(key),
+		ContentType:        optionalString(o.ContentType),
+		ContentDisposition: optionalString(o.ContentDisposition),
+		CacheControl:       optionalString(o.CacheControl),
+		ContentEncoding:    optionalString(o.ContentEncoding),
+		ACL:                optionalString(o.ACL),
+		Metadata:           aws.StringMap(o.Metadata),
+		Tagging:            optionalString(oss.EncodeTags(o.Tags)),
+	})
+	url, err := req.Presign(expire)
+	if err != nil {
+		return "", err
+	}
+	return r.genUrl(url), nil
+}
+
+func (r *awsS3) GenerateUploadPartUrl(ctx context.Context, key, uploadId string, partNumber int64, expire time.Duration) (string, error) {
+	err := oss.ValidateKey(key)
+	if err != nil {
+		return "", err
+	}
+	if expire > oss.MaxUrlExpire {
+		return "", oss.ErrExpireTooLong
+	}
+	req, _ := r.core.UploadPartRequest(&s3.UploadPartInput{
+		Bucket:     aws.String(r.bucket),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadId),
+		PartNumber: aws.Int64(partNumber),
+	})
+	url, err := req.Presign(expire)
+	if err != nil {
+		return "", err
+	}
+	return r.genUrl(url), nil
+}
+
 func (r *awsS3) genUrl(resource string) string {
 	if r.endpointAlias == "" {
 		return resource
@@ -149,10 +880,26 @@ func (r *awsS3) genUrl(resource string) string {
 }
 
 // 分片上传每个分片最小为 5MB，如果不适用需要用普通上传
-func (r *awsS3) CreateMultipartUpload(ctx context.Context, key string) (uploadId string, err error) {
+func (r *awsS3) CreateMultipartUpload(ctx context.Context, key string, opts ...oss.UploadOption) (uploadId string, err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return "", err
+	}
+	o := oss.NewUploadOptions(opts...)
+	if err := checkUploadOptions(o); err != nil {
+		return "", err
+	}
 	resp, err := r.core.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
-		Bucket: aws.String(r.bucket),
-		Key:    aws.String(key),
+		Bucket:             aws.String(r.bucket),
+		Key:                aws.String(key),
+		ContentType:        optionalString(o.ContentType),
+		ContentDisposition: optionalString(o.ContentDisposition),
+		CacheControl:       optionalString(o.CacheControl),
+		ContentEncoding:    optionalString(o.ContentEncoding),
+		ACL:                optionalString(o.ACL),
+		Metadata:           aws.StringMap(o.Metadata),
+		Tagging:            optionalString(oss.EncodeTags(o.Tags)),
 	})
 	if err != nil {
 		return "", err
@@ -161,6 +908,11 @@ func (r *awsS3) CreateMultipartUpload(ctx context.Context, key string) (uploadId
 }
 
 func (r *awsS3) UploadPart(ctx context.Context, key, uploadId string, partNumber int64, reader io.ReadSeeker) (etag string, err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return "", err
+	}
 	resp, err := r.core.UploadPart(&s3.UploadPartInput{
 		Bucket:     aws.String(r.bucket),
 		Key:        aws.String(key),
@@ -174,8 +926,13 @@ func (r *awsS3) UploadPart(ctx context.Context, key, uploadId string, partNumber
 	return *resp.ETag, nil
 }
 
-func (r *awsS3) AbortMultipartUpload(ctx context.Context, key, uploadId string) error {
-	_, err := r.core.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+func (r *awsS3) AbortMultipartUpload(ctx context.Context, key, uploadId string) (err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return err
+	}
+	_, err = r.core.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
 		Bucket:   aws.String(r.bucket),
 		Key:      aws.String(key),
 		UploadId: &uploadId,
@@ -195,6 +952,11 @@ func transformCompletedParts(parts []*oss.CompletedPart) []*s3.CompletedPart {
 }
 
 func (r *awsS3) CompleteMultipartUpload(ctx context.Context, key, uploadId string, partsNum int64) (etag string, err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return "", err
+	}
 	parts, err := r.ListParts(ctx, key, uploadId, 0)
 	if err != nil {
 		return
@@ -218,6 +980,11 @@ func (r *awsS3) CompleteMultipartUpload(ctx context.Context, key, uploadId strin
 // ListMultipartUploads 上传进行中但还没完成的分片
 // ListParts 上传完成的分片
 func (r *awsS3) ListParts(ctx context.Context, key, uploadId string, maxParts int64) (parts []*oss.CompletedPart, err error) {
+	defer wrapError(&err)
+	err = oss.ValidateKey(key)
+	if err != nil {
+		return nil, err
+	}
 	if maxParts == 0 {
 		maxParts = 1000
 	}
@@ -252,3 +1019,31 @@ func (r *awsS3) ListParts(ctx context.Context, key, uploadId string, maxParts in
 
 	return
 }
+
+// ListMultipartUploads 分页获取所有进行中的分片上传
+func (r *awsS3) ListMultipartUploads(ctx context.Context, prefix string) (_ []*oss.MultipartUpload, err error) {
+	defer wrapError(&err)
+	if err := checkPrefix(prefix); err != nil {
+		return nil, err
+	}
+
+	ret := make([]*oss.MultipartUpload, 0)
+	input := &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(r.bucket),
+		Prefix: aws.String(prefix),
+	}
+	err = r.core.ListMultipartUploadsPagesWithContext(ctx, input, func(page *s3.ListMultipartUploadsOutput, lastPage bool) bool {
+		for _, upload := range page.Uploads {
+			ret = append(ret, &oss.MultipartUpload{
+				Key:       aws.StringValue(upload.Key),
+				UploadId:  aws.StringValue(upload.UploadId),
+				Initiated: aws.TimeValue(upload.Initiated),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -7,12 +7,16 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"net/http"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/blues120/ias-kit/oss"
+	"github.com/blues120/ias-kit/oss/osstest"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -79,6 +83,17 @@ func (s *S3TestSuite) TestS3_Upload() {
 	require.NoError(s.T(), err)
 }
 
+func (s *S3TestSuite) TestS3_UploadStream() {
+	// 非 io.Seeker 的大文件会自动转为分片上传
+	reader := io.MultiReader(bytes.NewReader(s.totalMultiPartsData))
+	err := s.s3.Upload(context.Background(), s.multiPartsKey, reader)
+	require.NoError(s.T(), err)
+
+	info, err := s.s3.Stat(context.Background(), s.multiPartsKey)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), int64(len(s.totalMultiPartsData)), info.Size)
+}
+
 func (s *S3TestSuite) TestS3_Download() {
 	s.TestS3_Upload()
 
@@ -91,6 +106,51 @@ func (s *S3TestSuite) TestS3_Download() {
 	require.Equal(s.T(), s.ossData, actual)
 }
 
+func (s *S3TestSuite) TestS3_DownloadRange() {
+	s.TestS3_Upload()
+
+	readCloser, err := s.s3.DownloadRange(context.Background(), s.ossKey, 1, 1)
+	require.NoError(s.T(), err)
+	actual, err := io.ReadAll(readCloser)
+	readCloser.Close()
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), s.ossData[1:2], actual)
+
+	_, err = s.s3.DownloadRange(context.Background(), s.ossKey, int64(len(s.ossData)), 1)
+	require.ErrorIs(s.T(), err, oss.ErrInvalidRange)
+}
+
+func (s *S3TestSuite) TestS3_CopyAndMove() {
+	s.TestS3_Upload()
+
+	copyKey, moveKey := s.ossKey+"-copy", s.ossKey+"-move"
+	require.NoError(s.T(), s.s3.Copy(context.Background(), s.ossKey, copyKey))
+	require.NoError(s.T(), s.s3.Move(context.Background(), copyKey, moveKey))
+
+	exists, err := s.s3.Exists(context.Background(), copyKey)
+	require.NoError(s.T(), err)
+	require.False(s.T(), exists)
+	exists, err = s.s3.Exists(context.Background(), moveKey)
+	require.NoError(s.T(), err)
+	require.True(s.T(), exists)
+}
+
+func (s *S3TestSuite) TestS3_DeleteMany() {
+	keys := []string{"delete-test/1", "delete-test/2", "delete-test/3"}
+	for _, key := range keys {
+		require.NoError(s.T(), s.s3.Upload(context.Background(), key, bytes.NewReader(s.ossData)))
+	}
+
+	ret, err := s.s3.DeleteMany(context.Background(), keys[:1])
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), keys[:1], ret.Deleted)
+
+	ret, err = s.s3.DeletePrefix(context.Background(), "delete-test/")
+	require.NoError(s.T(), err)
+	require.ElementsMatch(s.T(), keys[1:], ret.Deleted)
+	require.Empty(s.T(), ret.Errors)
+}
+
 func (s *S3TestSuite) TestS3_Exists() {
 	_ = s.s3.Delete(context.Background(), s.ossKey)
 	exists, err := s.s3.Exists(context.Background(), s.ossKey)
@@ -103,6 +163,199 @@ func (s *S3TestSuite) TestS3_Exists() {
 	require.Equal(s.T(), true, exists)
 }
 
+func (s *S3TestSuite) TestS3_Stat() {
+	s.TestS3_Upload()
+
+	info, err := s.s3.Stat(context.Background(), s.ossKey)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), int64(len(s.ossData)), info.Size)
+	s.T().Logf("etag: %s, content type: %s, last modified: %s", info.ETag, info.ContentType, info.LastModified)
+}
+
+func (s *S3TestSuite) TestS3_Errors() {
+	ctx := context.Background()
+
+	_, err := s.s3.Stat(ctx, "not-exists")
+	require.ErrorIs(s.T(), err, oss.ErrNotFound)
+	_, err = s.s3.Download(ctx, "not-exists")
+	require.ErrorIs(s.T(), err, oss.ErrNotFound)
+	var awsErr awserr.Error
+	require.ErrorAs(s.T(), err, &awsErr)
+	require.Equal(s.T(), s3.ErrCodeNoSuchKey, awsErr.Code())
+
+	_, err = s.s3.ListParts(ctx, s.ossKey, "not-exists", 0)
+	require.ErrorIs(s.T(), err, oss.ErrUploadNotFound)
+}
+
+func (s *S3TestSuite) TestS3_Precondition() {
+	ctx := context.Background()
+	key := "precondition-test"
+	_ = s.s3.Delete(ctx, key)
+
+	require.NoError(s.T(), s.s3.Upload(ctx, key, bytes.NewReader([]byte("v1")), oss.WithIfNotExists()))
+	err := s.s3.Upload(ctx, key, bytes.NewReader([]byte("v2")), oss.WithIfNotExists())
+	require.ErrorIs(s.T(), err, oss.ErrPreconditionFailed)
+
+	info, err := s.s3.Stat(ctx, key)
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), s.s3.Upload(ctx, key, bytes.NewReader([]byte("v2")), oss.WithIfMatch(info.ETag)))
+	err = s.s3.Upload(ctx, key, bytes.NewReader([]byte("v3")), oss.WithIfMatch(info.ETag))
+	require.ErrorIs(s.T(), err, oss.ErrPreconditionFailed)
+
+	_, err = s.s3.Download(ctx, key, oss.WithDownloadIfMatch(info.ETag))
+	require.ErrorIs(s.T(), err, oss.ErrPreconditionFailed)
+}
+
+func (s *S3TestSuite) TestS3_Versioning() {
+	ctx := context.Background()
+	key := "versioning-test"
+	r := s.s3.(*awsS3)
+	putVersioning := func(status string) error {
+		_, err := r.core.PutBucketVersioning(&s3.PutBucketVersioningInput{
+			Bucket:                  aws.String(r.bucket),
+			VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String(status)},
+		})
+		return err
+	}
+	require.NoError(s.T(), putVersioning(s3.BucketVersioningStatusEnabled))
+	// 测试桶为共用，结束后暂停多版本，避免影响其他测试
+	t := s.T()
+	t.Cleanup(func() {
+		require.NoError(t, putVersioning(s3.BucketVersioningStatusSuspended))
+	})
+
+	for _, v := range []string{"v1", "v2"} {
+		require.NoError(s.T(), s.s3.Upload(ctx, key, bytes.NewReader([]byte(v))))
+	}
+	versions, err := s.s3.ListVersions(ctx, key)
+	require.NoError(s.T(), err)
+	require.GreaterOrEqual(s.T(), len(versions), 2)
+	require.True(s.T(), versions[0].IsLatest)
+
+	readCloser, err := s.s3.DownloadVersion(ctx, key, versions[1].VersionId)
+	require.NoError(s.T(), err)
+	data, err := io.ReadAll(readCloser)
+	readCloser.Close()
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "v1", string(data))
+
+	require.NoError(s.T(), s.s3.RestoreVersion(ctx, key, versions[1].VersionId))
+	require.NoError(s.T(), s.s3.DeleteVersion(ctx, key, versions[0].VersionId))
+
+	osstest.RunVersioning(s.T(), s.s3)
+}
+
+func (s *S3TestSuite) TestS3_Tags() {
+	ctx := context.Background()
+	key := "tags-test"
+	tags := map[string]string{"tenant": "a", "retention": "30d"}
+	require.NoError(s.T(), s.s3.Upload(ctx, key, bytes.NewReader(s.ossData), oss.WithTags(tags)))
+
+	actual, err := s.s3.GetTags(ctx, key)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), tags, actual)
+
+	require.NoError(s.T(), s.s3.SetTags(ctx, key, map[string]string{"source": "b"}))
+	actual, err = s.s3.GetTags(ctx, key)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), map[string]string{"source": "b"}, actual)
+
+	require.NoError(s.T(), s.s3.SetTags(ctx, key, nil))
+	actual, err = s.s3.GetTags(ctx, key)
+	require.NoError(s.T(), err)
+	require.Empty(s.T(), actual)
+}
+
+func (s *S3TestSuite) TestS3_PutExpirationRules() {
+	ctx := context.Background()
+	require.NoError(s.T(), PutExpirationRules(ctx, s.s3, []ExpirationRule{
+		{Prefix: "tmp/exports/", Days: 1},
+		{ID: "thumbnails", Prefix: "tmp/thumbnails/", Days: 7},
+	}))
+	// 重复配置时替换 id 相同的规则
+	require.NoError(s.T(), PutExpirationRules(ctx, s.s3, []ExpirationRule{{ID: "thumbnails", Prefix: "tmp/thumbnails/", Days: 3}}))
+
+	r := s.s3.(*awsS3)
+	resp, err := r.core.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{Bucket: aws.String(r.bucket)})
+	require.NoError(s.T(), err)
+	days := make(map[string]int64)
+	for _, rule := range resp.Rules {
+		days[aws.StringValue(rule.ID)] = aws.Int64Value(rule.Expiration.Days)
+	}
+	require.Equal(s.T(), int64(1), days["oss-expire-tmp/exports/"])
+	require.Equal(s.T(), int64(3), days["thumbnails"])
+
+	require.Error(s.T(), PutExpirationRules(ctx, s.s3, []ExpirationRule{{Prefix: "tmp/", Days: 0}}))
+}
+
+func (s *S3TestSuite) TestS3_ListMultipartUploads() {
+	ctx := context.Background()
+	key := "sweep-test/key"
+	uploadId, err := s.s3.CreateMultipartUpload(ctx, key)
+	require.NoError(s.T(), err)
+
+	uploads, err := s.s3.ListMultipartUploads(ctx, "sweep-test/")
+	require.NoError(s.T(), err)
+	require.Len(s.T(), uploads, 1)
+	require.Equal(s.T(), key, uploads[0].Key)
+	require.Equal(s.T(), uploadId, uploads[0].UploadId)
+
+	// 测试桶为共用，只终止本测试创建的分片上传，SweepStaleUploads 由 sweep_test.go 覆盖
+	require.NoError(s.T(), s.s3.AbortMultipartUpload(ctx, key, uploadId))
+	uploads, err = s.s3.ListMultipartUploads(ctx, "sweep-test/")
+	require.NoError(s.T(), err)
+	require.Empty(s.T(), uploads)
+}
+
+func (s *S3TestSuite) TestS3_RawKeys() {
+	ctx := context.Background()
+	// s3 的 key 不做规范化，列举返回的 key 可以直接访问
+	keys := []string{"raw-test/a//b", "raw-test/a/b", "raw-test/x/./y", "raw-test/dir/"}
+	for _, key := range keys {
+		require.NoError(s.T(), s.s3.Upload(ctx, key, bytes.NewReader([]byte(key))))
+	}
+	ret, err := s.s3.List(ctx, "raw-test/", nil)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), ret.Objects, len(keys))
+	for _, obj := range ret.Objects {
+		info, err := s.s3.Stat(ctx, obj.Key)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), int64(len(obj.Key)), info.Size)
+	}
+
+	deleted, err := s.s3.DeleteMany(ctx, keys)
+	require.NoError(s.T(), err)
+	require.ElementsMatch(s.T(), keys, deleted.Deleted)
+}
+
+func (s *S3TestSuite) TestS3_Conformance() {
+	osstest.Run(s.T(), s.s3)
+}
+
+func (s *S3TestSuite) TestS3_UploadWithOptions() {
+	err := s.s3.Upload(context.Background(), s.ossKey, bytes.NewReader(s.ossData),
+		oss.WithContentType("video/quicktime"),
+		oss.WithMetadata(map[string]string{"Tenant": "t1"}),
+	)
+	require.NoError(s.T(), err)
+
+	info, err := s.s3.Stat(context.Background(), s.ossKey)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "video/quicktime", info.ContentType)
+	require.Equal(s.T(), "t1", info.Metadata["tenant"])
+}
+
+func (s *S3TestSuite) TestS3_List() {
+	s.TestS3_Upload()
+
+	ret, err := s.s3.List(context.Background(), "", &oss.ListOptions{Delimiter: "/", MaxKeys: 10})
+	require.NoError(s.T(), err)
+	for _, obj := range ret.Objects {
+		s.T().Logf("object: %s, size: %d, etag: %s", obj.Key, obj.Size, obj.ETag)
+	}
+	s.T().Logf("common prefixes: %v, truncated: %v", ret.CommonPrefixes, ret.IsTruncated)
+}
+
 func (s *S3TestSuite) TestS3_GenerateUrl() {
 	url, err := s.s3.GenerateUrl(context.Background(), s.ossKey, time.Second*5)
 	require.NoError(s.T(), err)
@@ -115,6 +368,47 @@ func (s *S3TestSuite) TestS3_GeneratePermanentUrl() {
 	s.T().Logf("generate url: %s", url)
 }
 
+func (s *S3TestSuite) TestS3_GenerateUploadUrl() {
+	url, err := s.s3.GenerateUploadUrl(context.Background(), s.ossKey, time.Minute, oss.WithContentType("video/quicktime"))
+	require.NoError(s.T(), err)
+
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(s.ossData))
+	require.NoError(s.T(), err)
+	req.Header.Set("Content-Type", "video/quicktime")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(s.T(), err)
+	resp.Body.Close()
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+
+	info, err := s.s3.Stat(context.Background(), s.ossKey)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "video/quicktime", info.ContentType)
+
+	_, err = s.s3.GenerateUploadUrl(context.Background(), s.ossKey, oss.MaxUrlExpire+time.Second)
+	require.ErrorIs(s.T(), err, oss.ErrExpireTooLong)
+}
+
+func (s *S3TestSuite) TestS3_UploadMultiPartWithUrl() {
+	uploadId, err := s.s3.CreateMultipartUpload(context.Background(), s.multiPartsKey)
+	require.NoError(s.T(), err)
+
+	for i := 0; i < 2; i++ {
+		url, err := s.s3.GenerateUploadPartUrl(context.Background(), s.multiPartsKey, uploadId, int64(i+1), time.Minute)
+		require.NoError(s.T(), err)
+
+		req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(s.multiPartsData[i]))
+		require.NoError(s.T(), err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(s.T(), err)
+		resp.Body.Close()
+		require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+		s.T().Logf("partNum: %d, etag: %s", i+1, resp.Header.Get("ETag"))
+	}
+
+	_, err = s.s3.CompleteMultipartUpload(context.Background(), s.multiPartsKey, uploadId, 2)
+	require.NoError(s.T(), err)
+}
+
 func (s *S3TestSuite) TestS3_UploadMultiPart() {
 	uploadId, err := s.s3.CreateMultipartUpload(context.Background(), s.multiPartsKey)
 	require.NoError(s.T(), err)
@@ -0,0 +1,43 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SweepStaleUploads 终止创建时间早于 olderThan 之前的所有分片上传，清理客户端异常退出后残留的分片
+// 返回已终止的分片上传；单个分片上传终止失败时继续处理其余的，最后返回第一个错误
+func SweepStaleUploads(ctx context.Context, store Oss, olderThan time.Duration) ([]*MultipartUpload, error) {
+	uploads, err := store.ListMultipartUploads(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	aborted := make([]*MultipartUpload, 0)
+	var firstErr error
+	for _, upload := range uploads {
+		if err := ctx.Err(); err != nil {
+			return aborted, err
+		}
+		if !upload.Initiated.Before(cutoff) {
+			continue
+		}
+
+		err := store.AbortMultipartUpload(ctx, upload.Key, upload.UploadId)
+		// 列举后已完成或已终止
+		if errors.Is(err, ErrUploadNotFound) {
+			continue
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("abort multipart upload %s of %q: %w", upload.UploadId, upload.Key, err)
+			}
+			continue
+		}
+		aborted = append(aborted, upload)
+	}
+	return aborted, firstErr
+}
@@ -0,0 +1,35 @@
+package oss_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/blues120/ias-kit/oss"
+	"github.com/blues120/ias-kit/oss/local"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSweepStaleUploads(t *testing.T) {
+	ctx := context.Background()
+	store, err := local.NewLocal(t.TempDir(), "")
+	require.NoError(t, err)
+
+	uploadId, err := store.CreateMultipartUpload(ctx, "key")
+	require.NoError(t, err)
+
+	// 未超过时间不终止
+	aborted, err := oss.SweepStaleUploads(ctx, store, time.Hour)
+	require.NoError(t, err)
+	require.Empty(t, aborted)
+
+	aborted, err = oss.SweepStaleUploads(ctx, store, 0)
+	require.NoError(t, err)
+	require.Len(t, aborted, 1)
+	require.Equal(t, uploadId, aborted[0].UploadId)
+
+	uploads, err := store.ListMultipartUploads(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, uploads)
+	require.ErrorIs(t, store.AbortMultipartUpload(ctx, "key", uploadId), oss.ErrUploadNotFound)
+}
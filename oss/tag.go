@@ -0,0 +1,52 @@
+package oss
+
+import (
+	"fmt"
+	"net/url"
+	"unicode/utf8"
+)
+
+// 与 s3 相同的标签限制
+const (
+	MaxTags           = 10
+	MaxTagKeyLength   = 128
+	MaxTagValueLength = 256
+)
+
+// ValidateTags 校验标签数量及长度，标签的 key 不能为空
+func ValidateTags(tags map[string]string) error {
+	if len(tags) > MaxTags {
+		return fmt.Errorf("%w: up to %d tags are allowed", ErrInvalidTags, MaxTags)
+	}
+	for k, v := range tags {
+		if k == "" || utf8.RuneCountInString(k) > MaxTagKeyLength {
+			return fmt.Errorf("%w: the key %q must be 1 to %d characters", ErrInvalidTags, k, MaxTagKeyLength)
+		}
+		if utf8.RuneCountInString(v) > MaxTagValueLength {
+			return fmt.Errorf("%w: the value of %q must be up to %d characters", ErrInvalidTags, k, MaxTagValueLength)
+		}
+	}
+	return nil
+}
+
+// EncodeTags 将标签编码为 x-amz-tagging 头的格式，如 "tenant=a&source=b"
+func EncodeTags(tags map[string]string) string {
+	values := url.Values{}
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
+// ParseTags 解析 x-amz-tagging 头
+func ParseTags(s string) (map[string]string, error) {
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTags, err)
+	}
+	tags := make(map[string]string, len(values))
+	for k := range values {
+		tags[k] = values.Get(k)
+	}
+	return tags, nil
+}
@@ -0,0 +1,36 @@
+package oss
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTags(t *testing.T) {
+	require.NoError(t, ValidateTags(nil))
+	require.NoError(t, ValidateTags(map[string]string{"tenant": "a", "retention": ""}))
+
+	tooMany := make(map[string]string)
+	for i := 0; i <= MaxTags; i++ {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+	for _, tags := range []map[string]string{
+		tooMany,
+		{"": "v"},
+		{strings.Repeat("k", MaxTagKeyLength+1): "v"},
+		{"k": strings.Repeat("v", MaxTagValueLength+1)},
+	} {
+		require.ErrorIs(t, ValidateTags(tags), ErrInvalidTags)
+	}
+}
+
+func TestEncodeTags(t *testing.T) {
+	tags := map[string]string{"tenant": "a b", "source": "x&y=z"}
+	encoded := EncodeTags(tags)
+	require.Equal(t, "source=x%26y%3Dz&tenant=a+b", encoded)
+
+	parsed, err := ParseTags(encoded)
+	require.NoError(t, err)
+	require.Equal(t, tags, parsed)
+}
@@ -0,0 +1,208 @@
+package oss
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// MinPartSize s3 要求除最后一个分片外，每个分片不小于 5MB
+	MinPartSize = 5 << 20
+
+	// MaxUploadParts 单个分片上传最多的分片数
+	MaxUploadParts = 10000
+
+	DefaultPartSize          = 8 << 20
+	DefaultUploadConcurrency = 4
+	DefaultMaxRetries        = 3
+	DefaultRetryDelay        = 100 * time.Millisecond
+)
+
+// Uploader 基于分片上传接口并发上传大文件，仅依赖 Oss 接口，适用于任意实现
+type Uploader struct {
+	store Oss
+
+	// PartSize 分片大小，不小于 MinPartSize，同时上传的分片都缓存在内存中
+	PartSize int64
+
+	// Concurrency 同时上传的分片数
+	Concurrency int
+
+	// MaxRetries 单个分片失败后的最多重试次数，参数错误、分片上传不存在等错误不重试
+	MaxRetries int
+
+	// RetryDelay 首次重试前的等待时间，之后每次翻倍
+	RetryDelay time.Duration
+}
+
+// NewUploader 创建 Uploader，opts 用于修改默认参数
+//
+//	uploader := oss.NewUploader(store, func(u *oss.Uploader) {
+//		u.PartSize = 64 << 20
+//	})
+func NewUploader(store Oss, opts ...func(*Uploader)) *Uploader {
+	u := &Uploader{
+		store:       store,
+		PartSize:    DefaultPartSize,
+		Concurrency: DefaultUploadConcurrency,
+		MaxRetries:  DefaultMaxRetries,
+		RetryDelay:  DefaultRetryDelay,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// Upload 读取 reader 并按 PartSize 切分上传，数据不足一个分片时直接调用 Oss.Upload
+// 分片上传失败时终止分片上传，不留下已上传的分片
+// opts 与 Oss.Upload 相同；分片上传不支持条件上传，开始及完成分片上传前通过 Stat 检查条件，
+// 条件不满足时终止分片上传并返回 ErrPreconditionFailed，检查与完成之间的并发写入无法发现
+func (u *Uploader) Upload(ctx context.Context, key string, reader io.Reader, opts ...UploadOption) error {
+	if u.PartSize < MinPartSize {
+		return fmt.Errorf("oss: part size %d is less than %d", u.PartSize, MinPartSize)
+	}
+
+	pool := &sync.Pool{New: func() any {
+		buf := make([]byte, u.PartSize)
+		return &buf
+	}}
+	buf := pool.Get().(*[]byte)
+	n, err := io.ReadFull(reader, *buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		data := (*buf)[:n]
+		return retry(ctx, u.MaxRetries, u.RetryDelay, func() error {
+			return u.store.Upload(ctx, key, bytes.NewReader(data), opts...)
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	cond := NewUploadOptions(opts...).Precondition
+	if err := u.checkPrecondition(ctx, key, cond); err != nil {
+		return err
+	}
+	uploadId, err := u.store.CreateMultipartUpload(ctx, key, opts...)
+	if err != nil {
+		return err
+	}
+	partsNum, err := u.uploadParts(ctx, key, uploadId, reader, pool, buf, n)
+	if err == nil {
+		err = u.checkPrecondition(ctx, key, cond)
+	}
+	if err == nil {
+		_, err = u.store.CompleteMultipartUpload(ctx, key, uploadId, partsNum)
+	}
+	if err != nil {
+		// ctx 可能已取消，终止分片上传不受其影响
+		_ = u.store.AbortMultipartUpload(context.Background(), key, uploadId)
+		return err
+	}
+	return nil
+}
+
+// checkPrecondition 分片上传的各实现都不支持条件上传，通过 Stat 获取文件当前的 ETag 检查条件
+func (u *Uploader) checkPrecondition(ctx context.Context, key string, cond Precondition) error {
+	if cond.IsZero() {
+		return nil
+	}
+	info, err := u.store.Stat(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return cond.Check("", false)
+	}
+	if err != nil {
+		return err
+	}
+	return cond.Check(info.ETag, true)
+}
+
+// uploadParts 上传所有分片，first 为已读取的第一个分片，返回分片数
+func (u *Uploader) uploadParts(ctx context.Context, key, uploadId string, reader io.Reader, pool *sync.Pool, first *[]byte, n int) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+		mu.Unlock()
+	}
+
+	concurrency := u.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var partNumber int64
+	buf := first
+	for {
+		partNumber++
+		if partNumber > MaxUploadParts {
+			fail(fmt.Errorf("oss: upload exceeds %d parts, increase the part size", MaxUploadParts))
+			pool.Put(buf)
+			break
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			fail(ctx.Err())
+			pool.Put(buf)
+			break
+		}
+
+		wg.Add(1)
+		go func(partNumber int64, buf *[]byte, data []byte) {
+			defer func() {
+				pool.Put(buf)
+				<-sem
+				wg.Done()
+			}()
+			err := retry(ctx, u.MaxRetries, u.RetryDelay, func() error {
+				_, err := u.store.UploadPart(ctx, key, uploadId, partNumber, bytes.NewReader(data))
+				return err
+			})
+			if err != nil {
+				fail(fmt.Errorf("upload part %d: %w", partNumber, err))
+			}
+		}(partNumber, buf, (*buf)[:n])
+
+		if n < len(*buf) {
+			break
+		}
+		var err error
+		buf = pool.Get().(*[]byte)
+		n, err = io.ReadFull(reader, *buf)
+		if err == io.EOF {
+			pool.Put(buf)
+			break
+		}
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			fail(err)
+			pool.Put(buf)
+			break
+		}
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return 0, firstErr
+	}
+	return partNumber, nil
+}
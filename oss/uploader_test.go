@@ -0,0 +1,130 @@
+package oss_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/blues120/ias-kit/oss"
+	"github.com/blues120/ias-kit/oss/local"
+	"github.com/blues120/ias-kit/oss/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newUploader(store oss.Oss) *oss.Uploader {
+	return oss.NewUploader(store, func(u *oss.Uploader) {
+		u.PartSize = oss.MinPartSize
+		u.RetryDelay = 0
+	})
+}
+
+func requireContent(t *testing.T, store oss.Oss, key string, expected []byte) {
+	reader, err := store.Download(context.Background(), key)
+	require.NoError(t, err)
+	defer reader.Close()
+	actual, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(expected, actual))
+}
+
+func TestUploader_Upload(t *testing.T) {
+	ctx := context.Background()
+	store, err := local.NewLocal(t.TempDir(), "")
+	require.NoError(t, err)
+	uploader := newUploader(store)
+
+	for _, size := range []int{0, 10, oss.MinPartSize, 2*oss.MinPartSize + 10} {
+		data := make([]byte, size)
+		rand.Read(data)
+		require.NoError(t, uploader.Upload(ctx, "key", bytes.NewReader(data), oss.WithContentType("text/plain")))
+		requireContent(t, store, "key", data)
+
+		info, err := store.Stat(ctx, "key")
+		require.NoError(t, err)
+		require.Equal(t, "text/plain", info.ContentType)
+	}
+
+	uploads, err := store.ListMultipartUploads(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, uploads)
+}
+
+func TestUploader_Retry(t *testing.T) {
+	ctx := context.Background()
+	var calls int64
+	store := memory.NewMemory(
+		memory.WithHook(countCalls(&calls, "UploadPart")),
+		memory.WithHook(memory.FailTimes(2, errors.New("connection reset"), "UploadPart")),
+	)
+
+	data := make([]byte, 3*oss.MinPartSize)
+	rand.Read(data)
+	require.NoError(t, newUploader(store).Upload(ctx, "key", bytes.NewReader(data)))
+	requireContent(t, store, "key", data)
+	require.Equal(t, int64(5), atomic.LoadInt64(&calls))
+}
+
+func TestUploader_Abort(t *testing.T) {
+	ctx := context.Background()
+	data := make([]byte, 3*oss.MinPartSize)
+
+	for _, injected := range []struct {
+		times int
+		err   error
+	}{
+		// 重试次数用尽
+		{100, errors.New("connection reset")},
+		// 不重试的错误
+		{1, oss.ErrPermission},
+	} {
+		store := memory.NewMemory(memory.WithHook(memory.FailTimes(injected.times, injected.err, "UploadPart")))
+		err := newUploader(store).Upload(ctx, "key", bytes.NewReader(data))
+		require.ErrorIs(t, err, injected.err)
+
+		uploads, err := store.ListMultipartUploads(ctx, "")
+		require.NoError(t, err)
+		require.Empty(t, uploads)
+		exists, err := store.Exists(ctx, "key")
+		require.NoError(t, err)
+		require.False(t, exists)
+	}
+
+	err := oss.NewUploader(memory.NewMemory(), func(u *oss.Uploader) { u.PartSize = 1 }).Upload(ctx, "key", bytes.NewReader(data))
+	require.Error(t, err)
+}
+
+func TestUploader_Precondition(t *testing.T) {
+	ctx := context.Background()
+	data := make([]byte, 2*oss.MinPartSize+10)
+	rand.Read(data)
+
+	// 开始分片上传前文件已存在
+	store := memory.NewMemory()
+	require.NoError(t, store.Upload(ctx, "key", bytes.NewReader([]byte("1"))))
+	err := newUploader(store).Upload(ctx, "key", bytes.NewReader(data), oss.WithIfNotExists())
+	require.ErrorIs(t, err, oss.ErrPreconditionFailed)
+	requireContent(t, store, "key", []byte("1"))
+
+	// 上传分片期间文件被创建，完成分片上传前检查条件并终止
+	var once sync.Once
+	store = memory.NewMemory(memory.WithHook(func(ctx context.Context, op, key string) error {
+		if op == "UploadPart" {
+			once.Do(func() {
+				assert.NoError(t, store.Upload(ctx, key, bytes.NewReader([]byte("2"))))
+			})
+		}
+		return nil
+	}))
+	err = newUploader(store).Upload(ctx, "key", bytes.NewReader(data), oss.WithIfNotExists())
+	require.ErrorIs(t, err, oss.ErrPreconditionFailed)
+	requireContent(t, store, "key", []byte("2"))
+	uploads, err := store.ListMultipartUploads(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, uploads)
+}